@@ -0,0 +1,38 @@
+package pocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// AuthState is the in-progress part of the auth flow: the request token
+// waiting for the user's authorization and the redirect uri it was issued
+// for. Desktop apps that may restart before the user is done can save it
+// with SaveAuthState and pick up with RestoreAuthState and FetchAccessToken.
+type AuthState struct {
+	RequestToken string `json:"request_token"`
+	RedirectUri  string `json:"redirect_uri"`
+}
+
+// SaveAuthState writes requestToken and the client's redirect uri to w as
+// JSON.
+func (client *Client) SaveAuthState(w io.Writer, requestToken string) error {
+	state := AuthState{RequestToken: requestToken, RedirectUri: client.redirectUri}
+	return json.NewEncoder(w).Encode(state)
+}
+
+// RestoreAuthState reads a state written by SaveAuthState, restores the
+// client's redirect uri and returns the request token to pass to
+// FetchAccessToken.
+func (client *Client) RestoreAuthState(r io.Reader) (string, error) {
+	var state AuthState
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return "", fmt.Errorf("Error parsing auth state: %s", err)
+	}
+	if len(state.RequestToken) == 0 {
+		return "", fmt.Errorf("missing request token in auth state")
+	}
+	client.rememberRedirectUri(state.RedirectUri)
+	return state.RequestToken, nil
+}
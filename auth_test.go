@@ -0,0 +1,73 @@
+package pocket
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestResumeAuth(t *testing.T) {
+	var buf bytes.Buffer
+	client := NewClient("consumer-key", WithRedirectURI("https://app.example.com/done"))
+	if err := client.SaveAuthState(&buf, "request-token"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the app restarts
+	var params map[string]string
+	restarted := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		params = requestParams(t, r)
+		fmt.Fprint(w, "access_token=new-token&username=jane")
+	})
+	restarted.AccessToken = ""
+	requestToken, err := restarted.RestoreAuthState(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requestToken != "request-token" {
+		t.Errorf("restored request token %q", requestToken)
+	}
+	authUrl := restarted.GetAuthorizationUrl(requestToken, "")
+	if !strings.Contains(authUrl, url.QueryEscape("https://app.example.com/done")) {
+		t.Errorf("authorization url %s lacks the restored redirect uri", authUrl)
+	}
+
+	if err := restarted.FetchAccessToken(requestToken); err != nil {
+		t.Fatal(err)
+	}
+	if params["code"] != "request-token" {
+		t.Errorf("authorized with %v", params)
+	}
+	if restarted.AccessToken != "new-token" || restarted.Username != "jane" {
+		t.Errorf("got access token %q for %q", restarted.AccessToken, restarted.Username)
+	}
+
+	if _, err := restarted.RestoreAuthState(strings.NewReader(`{"redirect_uri":"x"}`)); err == nil {
+		t.Error("restored a state without a request token")
+	}
+}
+
+func TestGetAuthorizationUrlDeepLink(t *testing.T) {
+	const redirect = "myapp://auth?state=1&next=/list"
+	client := NewClient("consumer-key")
+	u, err := url.Parse(client.GetAuthorizationUrl("request-token", redirect))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if got := q.Get("redirect_uri"); got != redirect {
+		t.Errorf("redirect_uri %q, want %q", got, redirect)
+	}
+	if got := q.Get("request_token"); got != "request-token" {
+		t.Errorf("request_token %q, want request-token", got)
+	}
+
+	// the deep link is remembered for later calls with an empty one
+	u, _ = url.Parse(client.GetAuthorizationUrl("request-token", ""))
+	if got := u.Query().Get("redirect_uri"); got != redirect {
+		t.Errorf("stored redirect_uri %q, want %q", got, redirect)
+	}
+}
@@ -0,0 +1,86 @@
+package pocket
+
+import (
+	"sync"
+	"time"
+)
+
+// itemCache keeps the items fetched by GetItems for ttl, see WithItemCache.
+// A nil *itemCache caches nothing.
+type itemCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cachedItem
+}
+
+type cachedItem struct {
+	item    Item
+	expires time.Time
+}
+
+func newItemCache(ttl time.Duration) *itemCache {
+	return &itemCache{ttl: ttl, entries: make(map[string]cachedItem)}
+}
+
+func (c *itemCache) get(itemId string, now time.Time) (Item, bool) {
+	if c == nil {
+		return Item{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[itemId]
+	if !ok {
+		return Item{}, false
+	}
+	if !now.Before(entry.expires) {
+		delete(c.entries, itemId)
+		return Item{}, false
+	}
+	return entry.item, true
+}
+
+func (c *itemCache) put(item Item, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[item.ItemID] = cachedItem{item: item, expires: now.Add(c.ttl)}
+}
+
+func (c *itemCache) evict(itemIds ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range itemIds {
+		delete(c.entries, id)
+	}
+}
+
+func (c *itemCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]cachedItem)
+}
+
+// evictActions drops the items the actions of req refer to by id. Actions
+// that don't name an item, like tag_rename or an add by url, may change any
+// of them, so they clear the whole cache.
+func (c *itemCache) evictActions(req *ModifyRequest) {
+	if c == nil {
+		return
+	}
+	for _, a := range req.actions {
+		id, ok := a.Params["item_id"]
+		if !ok {
+			c.clear()
+			return
+		}
+		c.evict(id)
+	}
+}
@@ -0,0 +1,75 @@
+package pocket
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestItemCache(t *testing.T) {
+	clock := newFakeClock()
+	p := newFakePocket(t, numberedItems(3)...)
+	client := p.client(WithClock(clock), WithItemCache(time.Minute))
+
+	for i := 0; i < 2; i++ {
+		item, err := client.GetItem(context.Background(), "2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.ItemID != "2" {
+			t.Errorf("got item %q, want 2", item.ItemID)
+		}
+	}
+	if len(p.retrieves) != 1 {
+		t.Errorf("made %d retrieves, want the second GetItem served from the cache", len(p.retrieves))
+	}
+
+	// item 1 was seen on the way to item 2, but only what was asked for is kept
+	if _, err := client.GetItems(context.Background(), []string{"1", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.retrieves) != 2 {
+		t.Errorf("made %d retrieves, want one more for item 1", len(p.retrieves))
+	}
+
+	clock.Advance(time.Minute)
+	if _, err := client.GetItem(context.Background(), "2"); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.retrieves) != 3 {
+		t.Errorf("made %d retrieves, want the expired item fetched again", len(p.retrieves))
+	}
+}
+
+func TestItemCacheEviction(t *testing.T) {
+	p := newFakePocket(t, numberedItems(3)...)
+	client := p.client(WithItemCache(time.Hour))
+	ctx := context.Background()
+	fetches := func(itemIds ...string) int {
+		before := len(p.retrieves)
+		if _, err := client.GetItems(ctx, itemIds); err != nil {
+			t.Fatal(err)
+		}
+		return len(p.retrieves) - before
+	}
+
+	fetches("1", "2")
+	if _, err := client.FavoriteMany(ctx, []string{"1"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := fetches("2"); n != 0 {
+		t.Error("favoriting item 1 evicted item 2")
+	}
+	if n := fetches("1"); n == 0 {
+		t.Error("favorited item 1 was served from the cache")
+	}
+
+	req := new(ModifyRequest)
+	req.AddAction(Action{Kind: ActionTagRename, Params: map[string]string{"old_tag": "go", "new_tag": "golang"}})
+	if _, err := client.Modify(req); err != nil {
+		t.Fatal(err)
+	}
+	if n := fetches("2"); n == 0 {
+		t.Error("renaming a tag kept item 2 in the cache")
+	}
+}
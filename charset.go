@@ -0,0 +1,81 @@
+package pocket
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// windows1252 maps the bytes 0x80-0x9f of windows-1252 to runes; the other
+// bytes are the same as in latin-1. Unassigned bytes map to U+FFFD.
+var windows1252 = [32]rune{
+	'€', '�', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '�', 'Ž', '�',
+	'�', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '�', 'ž', 'Ÿ',
+}
+
+// private methods
+
+// toUTF8 transcodes body to UTF-8 according to the charset of contentType.
+// Bodies without a charset are taken to be UTF-8 already. Besides UTF-8 it
+// understands latin-1, windows-1252 and UTF-16.
+func toUTF8(body []byte, contentType string) ([]byte, error) {
+	if len(contentType) == 0 {
+		return body, nil
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// a malformed header isn't worth failing the request over
+		return body, nil
+	}
+
+	switch charset := strings.ToLower(params["charset"]); charset {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return body, nil
+	case "iso-8859-1", "latin1", "latin-1":
+		return decodeSingleByte(body, nil), nil
+	case "windows-1252", "cp1252":
+		return decodeSingleByte(body, &windows1252), nil
+	case "utf-16", "utf-16le", "utf-16be":
+		return decodeUTF16(body, charset), nil
+	default:
+		return nil, fmt.Errorf("Error parsing http response: unsupported charset %q", charset)
+	}
+}
+
+func decodeSingleByte(body []byte, high *[32]rune) []byte {
+	out := make([]byte, 0, len(body))
+	for _, b := range body {
+		r := rune(b)
+		if high != nil && b >= 0x80 && b < 0xa0 {
+			r = high[b-0x80]
+		}
+		out = utf8.AppendRune(out, r)
+	}
+	return out
+}
+
+// decodeUTF16 decodes UTF-16 in the given byte order. For plain "utf-16" a
+// byte order mark decides, defaulting to big endian.
+func decodeUTF16(body []byte, charset string) []byte {
+	bigEndian := charset != "utf-16le"
+	if charset == "utf-16" && len(body) >= 2 {
+		switch {
+		case body[0] == 0xfe && body[1] == 0xff:
+			body = body[2:]
+		case body[0] == 0xff && body[1] == 0xfe:
+			body, bigEndian = body[2:], false
+		}
+	}
+
+	units := make([]uint16, len(body)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(body[2*i])<<8 | uint16(body[2*i+1])
+		} else {
+			units[i] = uint16(body[2*i+1])<<8 | uint16(body[2*i])
+		}
+	}
+	return []byte(string(utf16.Decode(units)))
+}
@@ -0,0 +1,64 @@
+package pocket
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestLatin1Response(t *testing.T) {
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=ISO-8859-1")
+		w.Write([]byte("{\"status\":1,\"list\":{\"1\":{\"item_id\":\"1\",\"resolved_title\":\"Caf\xe9\"}}}"))
+	})
+	resp, err := client.RetrieveTyped(NewRetrieveRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.List["1"].ResolvedTitle; got != "Café" {
+		t.Errorf("got title %q, want Café", got)
+	}
+}
+
+func TestToUTF8(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		want        string
+	}{
+		{"", "Café", "Café"},
+		{"application/json", "Café", "Café"},
+		{"application/json; charset=utf-8", "Café", "Café"},
+		{"application/json; charset=latin1", "Caf\xe9", "Café"},
+		{"application/json; charset=windows-1252", "\x80 \x93q\x94", "€ “q”"},
+		{"application/json; charset=utf-16le", "C\x00a\x00f\x00\xe9\x00", "Café"},
+		{"application/json; charset=utf-16be", "\x00C\x00a\x00f\x00\xe9", "Café"},
+		{"application/json; charset=utf-16", "\xff\xfeC\x00a\x00f\x00\xe9\x00", "Café"},
+		{"application/json; charset=utf-16", "\x00C\x00a\x00f\x00\xe9", "Café"},
+		{"application/json; charset", "Café", "Café"},
+	}
+	for _, tt := range tests {
+		got, err := toUTF8([]byte(tt.body), tt.contentType)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("%q: got %q, %v, want %q", tt.contentType, got, err, tt.want)
+		}
+	}
+
+	if _, err := toUTF8([]byte("x"), "text/plain; charset=koi8-r"); err == nil {
+		t.Error("unsupported charset: got no error")
+	}
+}
+
+func TestErrorResponseUnsupportedCharset(t *testing.T) {
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=koi8-r")
+		w.Header().Set("X-Error-Code", "107")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("\xf0\xcf\xcb\xc5\xd4"))
+	})
+	_, err := client.Retrieve(NewRetrieveRequest())
+	var pErr *Error
+	if !errors.As(err, &pErr) || pErr.StatusCode != http.StatusUnauthorized || pErr.ErrorCode != 107 {
+		t.Errorf("got %v, want a 401 *Error", err)
+	}
+}
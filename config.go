@@ -0,0 +1,49 @@
+package pocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config is the format of the file read by NewClientFromConfigFile.
+type Config struct {
+	ConsumerKey string `json:"consumer_key"`
+	AccessToken string `json:"access_token"`
+	Username    string `json:"username"`
+}
+
+// NewClientFromConfigFile creates a client from a JSON config file such as
+//
+//	{"consumer_key": "...", "access_token": "...", "username": "..."}
+//
+// Values missing from the file are taken from the POCKET_CONSUMER_KEY,
+// POCKET_ACCESS_TOKEN and POCKET_USERNAME environment variables. The access
+// token and username are optional, but a consumer key is required.
+func NewClientFromConfigFile(path string, opts ...Option) (*Client, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var config Config
+	if err := json.NewDecoder(f).Decode(&config); err != nil {
+		return nil, fmt.Errorf("Error parsing config file %s: %s", path, err)
+	}
+	setFromEnv(&config.ConsumerKey, "POCKET_CONSUMER_KEY")
+	setFromEnv(&config.AccessToken, "POCKET_ACCESS_TOKEN")
+	setFromEnv(&config.Username, "POCKET_USERNAME")
+	if len(config.ConsumerKey) == 0 {
+		return nil, fmt.Errorf("no consumer key in %s or POCKET_CONSUMER_KEY", path)
+	}
+	return NewClientWithAccessToken(config.ConsumerKey, config.AccessToken, config.Username, opts...), nil
+}
+
+// private methods
+
+func setFromEnv(value *string, key string) {
+	if len(*value) == 0 {
+		*value = os.Getenv(key)
+	}
+}
@@ -0,0 +1,49 @@
+package pocket
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewClientFromConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	t.Setenv("POCKET_CONSUMER_KEY", "env-key")
+	t.Setenv("POCKET_ACCESS_TOKEN", "env-token")
+	t.Setenv("POCKET_USERNAME", "")
+
+	client, err := NewClientFromConfigFile(write("full.json",
+		`{"consumer_key":"file-key","access_token":"file-token","username":"jane"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.ConsumerToken != "file-key" || client.AccessToken != "file-token" || client.Username != "jane" {
+		t.Errorf("got %q, %q, %q", client.ConsumerToken, client.AccessToken, client.Username)
+	}
+
+	client, err = NewClientFromConfigFile(write("partial.json", `{"username":"jane"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.ConsumerToken != "env-key" || client.AccessToken != "env-token" {
+		t.Errorf("got %q, %q, want the environment's", client.ConsumerToken, client.AccessToken)
+	}
+
+	t.Setenv("POCKET_CONSUMER_KEY", "")
+	if _, err := NewClientFromConfigFile(write("nokey.json", `{}`)); err == nil {
+		t.Error("no consumer key: got no error")
+	}
+	if _, err := NewClientFromConfigFile(write("bad.json", `{`)); err == nil {
+		t.Error("malformed file: got no error")
+	}
+	if _, err := NewClientFromConfigFile(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("missing file: got no error")
+	}
+}
@@ -0,0 +1,91 @@
+package pocket
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// FindDeadLinks checks the url of every item (the resolved one if pocket has
+// it) with a HEAD request, at most concurrency at a time, and returns the
+// items whose url answered with a 4xx or 5xx status or couldn't be reached,
+// in the order given. Servers that don't allow HEAD are asked with GET
+// instead. The requests go through the client's http.Client but carry none of
+// the pocket specific headers. The error is only set when ctx ends first.
+func (client *Client) FindDeadLinks(ctx context.Context, items []Item, concurrency int) ([]Item, error) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	dead := make([]bool, len(items))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range items {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			dead[i] = client.isDeadLink(ctx, items[i].bestUrl())
+		}(i)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var deadItems []Item
+	for i, item := range items {
+		if dead[i] {
+			deadItems = append(deadItems, item)
+		}
+	}
+	return deadItems, nil
+}
+
+// ArchiveDeadLinks finds the dead links among items like FindDeadLinks and
+// archives them like ArchiveMany. It returns the dead items along with the
+// archive response.
+func (client *Client) ArchiveDeadLinks(ctx context.Context,
+	items []Item, concurrency int) ([]Item, *ModifyResponse, error) {
+	dead, err := client.FindDeadLinks(ctx, items, concurrency)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	itemIds := make([]string, len(dead))
+	for i, item := range dead {
+		itemIds[i] = item.ItemID
+	}
+	resp, err := client.ArchiveMany(ctx, itemIds)
+	return dead, resp, err
+}
+
+// private methods
+
+func (client *Client) isDeadLink(ctx context.Context, itemUrl string) bool {
+	status, err := client.linkStatus(ctx, "HEAD", itemUrl)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = client.linkStatus(ctx, "GET", itemUrl)
+	}
+	return err != nil || status >= 400
+}
+
+func (client *Client) linkStatus(ctx context.Context, method string, itemUrl string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, itemUrl, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.c.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,115 @@
+package pocket
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// roundTripFunc answers requests to any host, unlike handlerTransport, which
+// only stands in for pocket.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// linkChecker answers HEAD and GET requests for the hosts of the dead link
+// tests, and keeps track of how many are in flight at once.
+type linkChecker struct {
+	inFlight, maxInFlight int32
+}
+
+func (c *linkChecker) RoundTrip(req *http.Request) (*http.Response, error) {
+	n := atomic.AddInt32(&c.inFlight, 1)
+	defer atomic.AddInt32(&c.inFlight, -1)
+	for {
+		max := atomic.LoadInt32(&c.maxInFlight)
+		if n <= max || atomic.CompareAndSwapInt32(&c.maxInFlight, max, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+
+	status := http.StatusOK
+	switch req.URL.Host {
+	case "unreachable.example.com":
+		return nil, errors.New("connection refused")
+	case "gone.example.com":
+		status = http.StatusNotFound
+	case "broken.example.com":
+		status = http.StatusInternalServerError
+	case "nohead.example.com":
+		if req.Method == "HEAD" {
+			status = http.StatusMethodNotAllowed
+		}
+	}
+	return &http.Response{StatusCode: status, Header: make(http.Header),
+		Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+}
+
+func linkItems(hosts ...string) []Item {
+	items := make([]Item, len(hosts))
+	for i, host := range hosts {
+		items[i] = Item{ItemID: strconv.Itoa(i + 1), ResolvedURL: "https://" + host + "/page"}
+	}
+	return items
+}
+
+func TestFindDeadLinks(t *testing.T) {
+	checker := new(linkChecker)
+	client := NewClient("consumer-key", WithHTTPClient(&http.Client{Transport: checker}))
+	items := linkItems("live.example.com", "gone.example.com", "nohead.example.com",
+		"unreachable.example.com", "broken.example.com", "live.example.com", "live.example.com")
+
+	dead, err := client.FindDeadLinks(context.Background(), items, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := itemIdsOf(dead), []string{"2", "4", "5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got dead %v, want %v", got, want)
+	}
+	if max := atomic.LoadInt32(&checker.maxInFlight); max > 2 {
+		t.Errorf("had %d requests in flight, want at most 2", max)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.FindDeadLinks(ctx, items, 2); err != context.Canceled {
+		t.Errorf("cancelled: got %v, want context.Canceled", err)
+	}
+}
+
+func TestArchiveDeadLinks(t *testing.T) {
+	p := newFakePocket(t)
+	checker := new(linkChecker)
+	hc := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "getpocket.com" {
+			return handlerTransport(p.serveHTTP).RoundTrip(req)
+		}
+		return checker.RoundTrip(req)
+	})}
+	client := NewClientWithAccessToken("consumer-key", "access-token", "user", WithHTTPClient(hc))
+
+	items := linkItems("live.example.com", "gone.example.com", "broken.example.com")
+	dead, resp, err := client.ArchiveDeadLinks(context.Background(), items, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := itemIdsOf(dead); !reflect.DeepEqual(got, []string{"2", "3"}) {
+		t.Errorf("got dead %v, want [2 3]", got)
+	}
+	if got, want := actionsOf(p.modifies[0]), []string{"archive 2", "archive 3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+	if len(resp.ActionResults) != 2 {
+		t.Errorf("got %d results, want 2", len(resp.ActionResults))
+	}
+}
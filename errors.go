@@ -0,0 +1,97 @@
+package pocket
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors matched by the *Error returned for the corresponding
+// responses, e.g. errors.Is(err, ErrInvalidToken).
+var (
+	// ErrInvalidToken: pocket answered 401, the access token is invalid,
+	// expired or was revoked by the user.
+	ErrInvalidToken = errors.New("invalid or expired access token")
+	// ErrForbidden: pocket answered 403 for lack of permission.
+	ErrForbidden = errors.New("access denied")
+	// ErrRateLimited: pocket answered 403 and the rate limit headers show the
+	// user or consumer key quota is used up.
+	ErrRateLimited = errors.New("rate limit exceeded")
+)
+
+// ErrMissingConsumerKey is returned by calls on a client created without a
+// consumer key.
+var ErrMissingConsumerKey = errors.New("missing consumer key")
+
+// ErrNilRequest is returned when a nil request is passed to Retrieve, Add,
+// Modify or the calls built on them, such as RetrieveAllItems, Watch or
+// ArchiveMatching. An ItemIterator for a nil request reports it from Err.
+var ErrNilRequest = errors.New("nil request")
+
+// ValidationError is returned by RetrieveRequest.Validate, listing every
+// problem found with the request.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid request: " + strings.Join(e.Problems, "; ")
+}
+
+// Error codes documented by pocket in the X-Error-Code response header.
+const (
+	ErrCodeMissingConsumerKey int = 138
+	ErrCodeMissingRedirectUrl int = 140
+	ErrCodeInvalidConsumerKey int = 152
+	ErrCodeUserRejectedCode   int = 158
+	ErrCodeAlreadyUsedCode    int = 159
+	ErrCodeInvalidRedirectUri int = 181
+	ErrCodeMissingCode        int = 182
+	ErrCodeCodeNotFound       int = 185
+	ErrCodePocketServerIssue  int = 199
+)
+
+// ErrorDescriptions maps the documented pocket error codes to a readable
+// description. It is used to fill in Error.ErrorMsg when pocket doesn't send
+// an X-Error header.
+var ErrorDescriptions = map[int]string{
+	ErrCodeMissingConsumerKey: "Missing consumer key.",
+	ErrCodeMissingRedirectUrl: "Missing redirect url.",
+	ErrCodeInvalidConsumerKey: "Invalid consumer key.",
+	ErrCodeUserRejectedCode:   "User rejected code.",
+	ErrCodeAlreadyUsedCode:    "Already used code.",
+	ErrCodeInvalidRedirectUri: "Invalid redirect uri.",
+	ErrCodeMissingCode:        "Missing code.",
+	ErrCodeCodeNotFound:       "Code not found.",
+	ErrCodePocketServerIssue:  "Pocket server issue.",
+}
+
+func errorKind(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrInvalidToken
+	case http.StatusForbidden:
+		if resp.Header.Get("X-Limit-User-Remaining") == "0" ||
+			resp.Header.Get("X-Limit-Key-Remaining") == "0" {
+			return ErrRateLimited
+		}
+		return ErrForbidden
+	}
+	return nil
+}
+
+// HTTPStatus maps the error to a status a server proxying pocket can return
+// to its own clients: invalid tokens stay 401, rate limiting becomes 429,
+// other client errors keep pocket's status and server errors (or anything
+// unexpected) become 502 Bad Gateway.
+func (e *Error) HTTPStatus() int {
+	switch {
+	case e.kind == ErrInvalidToken:
+		return http.StatusUnauthorized
+	case e.kind == ErrRateLimited:
+		return http.StatusTooManyRequests
+	case e.StatusCode >= 400 && e.StatusCode < 500:
+		return e.StatusCode
+	}
+	return http.StatusBadGateway
+}
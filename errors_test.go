@@ -0,0 +1,128 @@
+package pocket
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestErrorDescriptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  http.Header
+		wantMsg string
+	}{
+		{"known code without message",
+			http.Header{"X-Error-Code": {"152"}}, "Invalid consumer key."},
+		{"message sent",
+			http.Header{"X-Error-Code": {"152"}, "X-Error": {"Bad key"}}, "Bad key"},
+		{"unknown code", http.Header{"X-Error-Code": {"42"}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+				for k, vs := range tt.header {
+					w.Header()[k] = vs
+				}
+				w.WriteHeader(http.StatusBadRequest)
+			})
+			_, err := client.Retrieve(NewRetrieveRequest())
+			var pErr *Error
+			if !errors.As(err, &pErr) {
+				t.Fatalf("got %v, want an *Error", err)
+			}
+			if pErr.ErrorMsg != tt.wantMsg {
+				t.Errorf("got message %q, want %q", pErr.ErrorMsg, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		want   error
+	}{
+		{"unauthorized", http.StatusUnauthorized, nil, ErrInvalidToken},
+		{"forbidden", http.StatusForbidden, http.Header{"X-Limit-User-Remaining": {"10"}}, ErrForbidden},
+		{"user rate limited", http.StatusForbidden, http.Header{"X-Limit-User-Remaining": {"0"}}, ErrRateLimited},
+		{"key rate limited", http.StatusForbidden, http.Header{"X-Limit-Key-Remaining": {"0"}}, ErrRateLimited},
+	}
+	kinds := []error{ErrInvalidToken, ErrForbidden, ErrRateLimited}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+				for k, vs := range tt.header {
+					w.Header()[k] = vs
+				}
+				w.WriteHeader(tt.status)
+			})
+			_, err := client.Retrieve(NewRetrieveRequest())
+			for _, kind := range kinds {
+				if got := errors.Is(err, kind); got != (kind == tt.want) {
+					t.Errorf("errors.Is(%v, %v) = %v", err, kind, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		err  *Error
+		want int
+	}{
+		{&Error{StatusCode: 401, kind: ErrInvalidToken}, 401},
+		{&Error{StatusCode: 403, kind: ErrRateLimited}, 429},
+		{&Error{StatusCode: 403, kind: ErrForbidden}, 403},
+		{&Error{StatusCode: 400}, 400},
+		{&Error{StatusCode: 500}, 502},
+		{&Error{StatusCode: 503}, 502},
+		{&Error{StatusCode: 302}, 502},
+	}
+	for _, tt := range tests {
+		if got := tt.err.HTTPStatus(); got != tt.want {
+			t.Errorf("%d (%v): got %d, want %d", tt.err.StatusCode, tt.err.kind, got, tt.want)
+		}
+	}
+}
+
+func TestErrorBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   http.Header
+		body     string
+		wantCode int
+		wantMsg  string
+	}{
+		{"error and error_code", nil, `{"error":"Bad things","error_code":"107"}`, 107, "Bad things"},
+		{"message and code", nil, `{"message":"Bad things","code":107}`, 107, "Bad things"},
+		{"nested", nil, `{"error":{"message":"Bad things","code":"107"}}`, 107, "Bad things"},
+		{"headers win", http.Header{"X-Error-Code": {"152"}, "X-Error": {"From header"}},
+			`{"error":"Bad things","error_code":"107"}`, 152, "From header"},
+		{"code from body, description", nil, `{"error_code":152}`, 152, "Invalid consumer key."},
+		{"not json", nil, `<html>Bad Gateway</html>`, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+				for k, vs := range tt.header {
+					w.Header()[k] = vs
+				}
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, tt.body)
+			})
+			_, err := client.Retrieve(NewRetrieveRequest())
+			var pErr *Error
+			if !errors.As(err, &pErr) {
+				t.Fatalf("got %v, want an *Error", err)
+			}
+			if pErr.ErrorCode != tt.wantCode || pErr.ErrorMsg != tt.wantMsg {
+				t.Errorf("got %d %q, want %d %q", pErr.ErrorCode, pErr.ErrorMsg, tt.wantCode, tt.wantMsg)
+			}
+		})
+	}
+}
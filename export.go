@@ -0,0 +1,75 @@
+package pocket
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// TitlePreference chooses which of an item's titles the exporters use.
+type TitlePreference int
+
+const (
+	// TitleBest is the resolved title, or the given one if there's none.
+	TitleBest     TitlePreference = iota
+	TitleGiven    TitlePreference = iota
+	TitleResolved TitlePreference = iota
+)
+
+// WriteMarkdown writes items as a Markdown bulleted list of links with their
+// tags, e.g. for a "what I read" post. An item is written as
+//
+//	[Some article](https://example.com/article) (tags: go, tools)
+//
+// preceded by "- ". Items without the preferred title are linked by their
+// url.
+func WriteMarkdown(w io.Writer, items []Item, pref TitlePreference) error {
+	for _, item := range items {
+		itemUrl := item.bestUrl()
+		title := item.exportTitle(pref)
+		if len(title) == 0 {
+			title = itemUrl
+		}
+
+		line := fmt.Sprintf("- [%s](%s)", markdownEscaper.Replace(title), markdownUrlEscaper.Replace(itemUrl))
+		if len(item.Tags) > 0 {
+			line += fmt.Sprintf(" (tags: %s)", markdownEscaper.Replace(strings.Join(item.Tags, ", ")))
+		}
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// private methods
+
+// markdownEscaper escapes what would break a link's text out of its brackets
+// or start other formatting.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, `[`, `\[`, `]`, `\]`, `*`, `\*`, `_`, `\_`, "`", "\\`", "\n", " ",
+)
+
+// markdownUrlEscaper escapes what would end a link's url early.
+var markdownUrlEscaper = strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29")
+
+// exportTitle returns the preferred title with HTML entities decoded, or ""
+// if the item doesn't have it.
+func (item *Item) exportTitle(pref TitlePreference) string {
+	switch pref {
+	case TitleGiven:
+		return html.UnescapeString(item.GivenTitle)
+	case TitleResolved:
+		return html.UnescapeString(item.ResolvedTitle)
+	default:
+		return item.DecodedTitle()
+	}
+}
+
+func (item *Item) bestUrl() string {
+	if len(item.ResolvedURL) > 0 {
+		return item.ResolvedURL
+	}
+	return item.GivenURL
+}
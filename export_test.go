@@ -0,0 +1,50 @@
+package pocket
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	items := []Item{
+		{ResolvedURL: "https://example.com/a", ResolvedTitle: "Tom &amp; Jerry", Tags: []string{"go", "tools"}},
+		{GivenURL: "https://example.com/b", GivenTitle: "Given only"},
+		{GivenURL: "https://example.com/c (draft)"},
+		{ResolvedURL: "https://example.com/d", ResolvedTitle: "[Brackets] and *stars*"},
+	}
+	var buf bytes.Buffer
+	if err := WriteMarkdown(&buf, items, TitleBest); err != nil {
+		t.Fatal(err)
+	}
+	want := "- [Tom & Jerry](https://example.com/a) (tags: go, tools)\n" +
+		"- [Given only](https://example.com/b)\n" +
+		"- [https://example.com/c (draft)](https://example.com/c%20%28draft%29)\n" +
+		"- [\\[Brackets\\] and \\*stars\\*](https://example.com/d)\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteMarkdownTitlePreference(t *testing.T) {
+	items := []Item{
+		{ResolvedURL: "https://example.com/a", GivenTitle: "Given &amp; saved", ResolvedTitle: "Resolved"},
+		{GivenURL: "https://example.com/b", GivenTitle: "Only given"},
+	}
+	tests := []struct {
+		pref TitlePreference
+		want string
+	}{
+		{TitleBest, "- [Resolved](https://example.com/a)\n- [Only given](https://example.com/b)\n"},
+		{TitleGiven, "- [Given & saved](https://example.com/a)\n- [Only given](https://example.com/b)\n"},
+		{TitleResolved, "- [Resolved](https://example.com/a)\n- [https://example.com/b](https://example.com/b)\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := WriteMarkdown(&buf, items, tt.pref); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("preference %d: got\n%s\nwant\n%s", tt.pref, got, tt.want)
+		}
+	}
+}
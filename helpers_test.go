@@ -0,0 +1,281 @@
+package pocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// handlerTransport answers requests with a handler function instead of
+// sending them over the network. Like http.Transport, it fails requests whose
+// context is done.
+type handlerTransport func(http.ResponseWriter, *http.Request)
+
+func (h handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	w := httptest.NewRecorder()
+	h(w, req)
+	resp := w.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+// newTestClient returns a client with credentials whose requests are answered
+// by handler. opts are applied after the test transport is installed.
+func newTestClient(handler func(http.ResponseWriter, *http.Request), opts ...Option) *Client {
+	hc := &http.Client{Transport: handlerTransport(handler)}
+	return NewClientWithAccessToken("consumer-key", "access-token", "user",
+		append([]Option{WithHTTPClient(hc)}, opts...)...)
+}
+
+// requestParams returns the params of a request to pocket, whether they were
+// sent in the query string, as a form or as a JSON object. Values that aren't
+// strings in a JSON body (like the actions of a modify POST) are returned in
+// their JSON encoding.
+func requestParams(t *testing.T, req *http.Request) map[string]string {
+	t.Helper()
+	params := make(map[string]string)
+	for k, vs := range req.URL.Query() {
+		params[k] = vs[0]
+	}
+	if req.Body == nil {
+		return params
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Errorf("reading request body: %v", err)
+		return params
+	}
+	if len(body) == 0 {
+		return params
+	}
+
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		var m map[string]interface{}
+		if err := json.Unmarshal(body, &m); err != nil {
+			t.Errorf("request body %s: %v", body, err)
+			return params
+		}
+		for k, v := range m {
+			if s, ok := v.(string); ok {
+				params[k] = s
+			} else {
+				b, _ := json.Marshal(v)
+				params[k] = string(b)
+			}
+		}
+		return params
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		t.Errorf("request body %s: %v", body, err)
+		return params
+	}
+	for k, vs := range form {
+		params[k] = vs[0]
+	}
+	return params
+}
+
+// requestActions returns the actions of a modify call from its params.
+func requestActions(t *testing.T, params map[string]string) []map[string]string {
+	t.Helper()
+	var actions []map[string]string
+	if err := json.Unmarshal([]byte(params["actions"]), &actions); err != nil {
+		t.Errorf("actions %q: %v", params["actions"], err)
+	}
+	return actions
+}
+
+// fakePocket is an in-memory stand-in for pocket's item endpoints. Retrieves
+// page through items, honoring count and offset but no filters. Adds and add
+// actions save nothing but answer with a new item, and other actions succeed
+// unless failAction says otherwise. Every call is recorded.
+type fakePocket struct {
+	t *testing.T
+	// items are JSON encoded items in list order; sort_id is filled in
+	items []string
+
+	// since is sent as the since of every retrieve response
+	since int64
+	// total makes retrieves that ask for it (total=1) report len(items)
+	total bool
+	// failAction reports whether an action of a modify call fails
+	failAction func(action map[string]string) bool
+
+	mu        sync.Mutex
+	nextId    int
+	retrieves []map[string]string
+	adds      []map[string]string
+	modifies  [][]map[string]string
+	methods   []string // of the modify calls
+}
+
+func newFakePocket(t *testing.T, items ...string) *fakePocket {
+	return &fakePocket{t: t, items: items, nextId: 1000}
+}
+
+// client returns a client talking to p.
+func (p *fakePocket) client(opts ...Option) *Client {
+	return newTestClient(p.serveHTTP, opts...)
+}
+
+func (p *fakePocket) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	params := requestParams(p.t, req)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch req.URL.Path {
+	case "/v3/get":
+		p.retrieves = append(p.retrieves, params)
+		fmt.Fprint(w, p.retrieveResponse(params))
+	case "/v3/add":
+		p.adds = append(p.adds, params)
+		fmt.Fprintf(w, `{"status":1,"item":%s}`, p.newItem(params["url"]))
+	case "/v3/send":
+		actions := requestActions(p.t, params)
+		p.modifies = append(p.modifies, actions)
+		p.methods = append(p.methods, req.Method)
+		results := make([]string, len(actions))
+		for i, a := range actions {
+			switch {
+			case p.failAction != nil && p.failAction(a):
+				results[i] = "false"
+			case a["action"] == "add":
+				results[i] = p.newItem(a["url"])
+			default:
+				results[i] = "true"
+			}
+		}
+		fmt.Fprintf(w, `{"status":1,"action_results":[%s]}`, strings.Join(results, ","))
+	default:
+		p.t.Errorf("unexpected request %s %s", req.Method, req.URL)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (p *fakePocket) retrieveResponse(params map[string]string) string {
+	items := p.items
+	if offset := atoi(params["offset"]); offset < len(items) {
+		items = items[offset:]
+	} else {
+		items = nil
+	}
+	if count := atoi(params["count"]); count > 0 && count < len(items) {
+		items = items[:count]
+	}
+
+	list := make(map[string]json.RawMessage)
+	for i, raw := range items {
+		var item map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(raw), &item); err != nil {
+			p.t.Errorf("fake item %s: %v", raw, err)
+			continue
+		}
+		if _, ok := item["sort_id"]; !ok {
+			item["sort_id"] = json.RawMessage(strconv.Itoa(i))
+		}
+		var id flexString
+		json.Unmarshal(item["item_id"], &id)
+		list[string(id)], _ = json.Marshal(item)
+	}
+
+	resp := map[string]interface{}{"status": 1, "complete": 1, "since": p.since, "list": list}
+	if len(list) == 0 {
+		// like pocket, send an empty array rather than an empty object
+		resp["list"] = []string{}
+	}
+	if p.total && params["total"] == "1" {
+		resp["total"] = strconv.Itoa(len(p.items))
+	}
+	b, _ := json.Marshal(resp)
+	return string(b)
+}
+
+func (p *fakePocket) newItem(itemUrl string) string {
+	p.nextId++
+	b, _ := json.Marshal(map[string]string{
+		"item_id":     strconv.Itoa(p.nextId),
+		"resolved_id": strconv.Itoa(p.nextId),
+		"given_url":   itemUrl,
+		"normal_url":  itemUrl,
+	})
+	return string(b)
+}
+
+// actionsOf returns the action names and item ids of actions, e.g.
+// "archive 1", for comparing against what a test expects.
+func actionsOf(actions []map[string]string) []string {
+	var l []string
+	for _, a := range actions {
+		s := a["action"] + " " + a["item_id"]
+		if tags, ok := a["tags"]; ok {
+			s += " " + tags
+		}
+		l = append(l, s)
+	}
+	return l
+}
+
+// itemIdsOf returns the ids of items in order.
+func itemIdsOf(items []Item) []string {
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ItemID)
+	}
+	return ids
+}
+
+// numberedItems returns n items with the ids 1 to n.
+func numberedItems(n int) []string {
+	items := make([]string, n)
+	for i := range items {
+		items[i] = fmt.Sprintf(`{"item_id":"%d","given_url":"https://example.com/%d"}`, i+1, i+1)
+	}
+	return items
+}
+
+// fakeClock is a Clock whose time only moves when the client waits on it or
+// a test advances it. Waits return at once, after moving the time forward.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,540 @@
+package pocket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ItemStatus int
+
+const (
+	StatusUnread   ItemStatus = iota
+	StatusArchived ItemStatus = iota
+	StatusDeleted  ItemStatus = iota
+)
+
+// Item is the typed form of an item returned by the pocket API. Fields that
+// pocket didn't send are left at their zero value.
+type Item struct {
+	ItemID        string
+	ResolvedID    string
+	GivenURL      string
+	GivenTitle    string
+	ResolvedURL   string
+	ResolvedTitle string
+	Excerpt       string
+	IsArticle     bool
+	HasImage      int
+	HasVideo      int
+	WordCount     int
+	Lang          string
+	Favorite      bool
+	Status        ItemStatus
+	TimeAdded     int64
+	TimeUpdated   int64
+	TimeRead      int64
+	TimeFavorited int64
+	TopImageURL   string
+	Images        []ItemImage
+
+	// ListenDurationEstimate is pocket's estimate, in seconds, of how long
+	// the article takes to listen to.
+	ListenDurationEstimate int
+
+	Annotations []Annotation
+
+	// Tags holds the item's tags sorted by name (complete detail only).
+	Tags []string
+
+	// Recognized reports whether pocket could parse the page it was given,
+	// i.e. it normalized the url and resolved it to an item. It's only set
+	// on items returned by the add endpoint (AddTyped, QuickAdd).
+	Recognized bool
+	// ResponseCode is the http status pocket got fetching the page, or 0 if
+	// unknown. It's only set by the add endpoint.
+	ResponseCode int
+
+	// SortID is the item's position in the order pocket returned the list
+	// in, starting at 0. It's only meaningful within one response.
+	SortID int
+
+	hasSortId bool
+	// complete is set when fields only sent with complete detail were found
+	complete bool
+}
+
+// Annotation is a highlight the user made in an item.
+type Annotation struct {
+	AnnotationID string
+	Quote        string
+	Patch        string
+	Version      int
+	CreatedAt    string
+}
+
+// ItemImage is one of the images pocket found in an item (complete detail
+// only).
+type ItemImage struct {
+	ImageID string
+	Src     string
+	Width   int
+	Height  int
+	Credit  string
+	Caption string
+}
+
+// itemJson mirrors pocket's encoding of an item. Pocket usually sends numbers
+// and flags as strings but not always, so every field accepts any scalar;
+// numbers are decoded with flexInt.
+// The add endpoint sends the resolved title as "title".
+type itemJson struct {
+	ItemId        flexString      `json:"item_id"`
+	ResolvedId    flexString      `json:"resolved_id"`
+	GivenUrl      flexString      `json:"given_url"`
+	GivenTitle    flexString      `json:"given_title"`
+	ResolvedUrl   flexString      `json:"resolved_url"`
+	ResolvedTitle flexString      `json:"resolved_title"`
+	Title         flexString      `json:"title"`
+	NormalUrl     flexString      `json:"normal_url"`
+	ResponseCode  flexInt         `json:"response_code"`
+	Excerpt       flexString      `json:"excerpt"`
+	IsArticle     flexString      `json:"is_article"`
+	HasImage      flexInt         `json:"has_image"`
+	HasVideo      flexInt         `json:"has_video"`
+	WordCount     flexInt         `json:"word_count"`
+	Lang          flexString      `json:"lang"`
+	Favorite      flexString      `json:"favorite"`
+	Status        flexInt         `json:"status"`
+	TimeAdded     flexInt         `json:"time_added"`
+	TimeUpdated   flexInt         `json:"time_updated"`
+	TimeRead      flexInt         `json:"time_read"`
+	TimeFavorited flexInt         `json:"time_favorited"`
+	SortId        flexString      `json:"sort_id"`
+	TopImageUrl   flexString      `json:"top_image_url"`
+	Images        json.RawMessage `json:"images"`
+
+	ListenDurationEstimate flexInt `json:"listen_duration_estimate"`
+
+	Annotations json.RawMessage `json:"annotations"`
+	Tags        json.RawMessage `json:"tags"`
+
+	// only checked for presence, to detect complete detail
+	Authors        json.RawMessage `json:"authors"`
+	Videos         json.RawMessage `json:"videos"`
+	DomainMetadata json.RawMessage `json:"domain_metadata"`
+}
+
+type tagJson struct {
+	Tag flexString `json:"tag"`
+}
+
+type annotationJson struct {
+	AnnotationId flexString `json:"annotation_id"`
+	Quote        flexString `json:"quote"`
+	Patch        flexString `json:"patch"`
+	Version      flexInt    `json:"version"`
+	CreatedAt    flexString `json:"created_at"`
+}
+
+type imageJson struct {
+	ImageId flexString `json:"image_id"`
+	Src     flexString `json:"src"`
+	Width   flexInt    `json:"width"`
+	Height  flexInt    `json:"height"`
+	Credit  flexString `json:"credit"`
+	Caption flexString `json:"caption"`
+}
+
+func (item *Item) UnmarshalJSON(b []byte) error {
+	var j itemJson
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+
+	item.ItemID = string(j.ItemId)
+	item.ResolvedID = string(j.ResolvedId)
+	item.GivenURL = string(j.GivenUrl)
+	item.GivenTitle = string(j.GivenTitle)
+	item.ResolvedURL = string(j.ResolvedUrl)
+	item.ResolvedTitle = string(j.ResolvedTitle)
+	if len(item.ResolvedTitle) == 0 {
+		item.ResolvedTitle = string(j.Title)
+	}
+	item.Excerpt = string(j.Excerpt)
+	item.IsArticle = j.IsArticle == "1"
+	item.HasImage = int(j.HasImage)
+	item.HasVideo = int(j.HasVideo)
+	item.WordCount = int(j.WordCount)
+	item.Lang = string(j.Lang)
+	item.Favorite = j.Favorite == "1"
+	item.Status = ItemStatus(j.Status)
+	item.TimeAdded = int64(j.TimeAdded)
+	item.TimeUpdated = int64(j.TimeUpdated)
+	item.TimeRead = int64(j.TimeRead)
+	item.TimeFavorited = int64(j.TimeFavorited)
+	item.SortID = atoi(string(j.SortId))
+	item.hasSortId = len(j.SortId) > 0
+	item.TopImageURL = string(j.TopImageUrl)
+	item.ListenDurationEstimate = int(j.ListenDurationEstimate)
+	item.Recognized = len(j.NormalUrl) > 0 && len(j.ResolvedId) > 0 && j.ResolvedId != "0"
+	item.ResponseCode = int(j.ResponseCode)
+
+	images, err := keyedValues(j.Images)
+	if err != nil {
+		return err
+	}
+	item.Images = nil
+	for _, raw := range images {
+		var ij imageJson
+		if err := json.Unmarshal(raw, &ij); err != nil {
+			return err
+		}
+		item.Images = append(item.Images, ItemImage{
+			ImageID: string(ij.ImageId),
+			Src:     string(ij.Src),
+			Width:   int(ij.Width),
+			Height:  int(ij.Height),
+			Credit:  string(ij.Credit),
+			Caption: string(ij.Caption),
+		})
+	}
+
+	annotations, err := keyedValues(j.Annotations)
+	if err != nil {
+		return err
+	}
+	item.Annotations = nil
+	for _, raw := range annotations {
+		var aj annotationJson
+		if err := json.Unmarshal(raw, &aj); err != nil {
+			return err
+		}
+		item.Annotations = append(item.Annotations, Annotation{
+			AnnotationID: string(aj.AnnotationId),
+			Quote:        string(aj.Quote),
+			Patch:        string(aj.Patch),
+			Version:      int(aj.Version),
+			CreatedAt:    string(aj.CreatedAt),
+		})
+	}
+
+	item.complete = j.Authors != nil || j.Videos != nil || j.DomainMetadata != nil ||
+		j.Images != nil || j.Tags != nil
+
+	item.Tags, err = decodeTags(j.Tags)
+	return err
+}
+
+// HasCompleteDetail reports whether the item was decoded with fields that
+// pocket only sends with complete detail (authors, images, videos, tags or
+// domain metadata), so a UI can tell whether to ask for more. Pocket leaves
+// these out when empty, so an item with none of them reports false even if
+// it was retrieved with complete detail.
+func (item *Item) HasCompleteDetail() bool {
+	return item.complete
+}
+
+// Thumbnail returns the url of an image to show for the item: the top image
+// if pocket found one, otherwise the first of its images. It returns "" when
+// the item has no images (or was retrieved without complete detail).
+func (item *Item) Thumbnail() string {
+	if len(item.TopImageURL) > 0 {
+		return item.TopImageURL
+	}
+	if len(item.Images) > 0 {
+		return item.Images[0].Src
+	}
+	return ""
+}
+
+// Resolved reports whether pocket resolved the item to a page, rather than
+// e.g. a dead link: it has a resolved id and, when known, fetching the page
+// didn't fail.
+func (item *Item) Resolved() bool {
+	if len(item.ResolvedID) == 0 || item.ResolvedID == "0" {
+		return false
+	}
+	return item.ResponseCode == 0 || (item.ResponseCode >= 200 && item.ResponseCode < 400)
+}
+
+// DecodedTitle returns the item's title with HTML entities such as &amp;
+// decoded, as pocket sometimes sends titles still encoded. It prefers the
+// resolved title and falls back to the title the item was saved with.
+func (item *Item) DecodedTitle() string {
+	return html.UnescapeString(item.bestTitle())
+}
+
+// AddTyped saves the url like Add and returns the item pocket resolved it to.
+// The add endpoint only returns part of an item: there is no status,
+// favorite or time information.
+func (client *Client) AddTyped(req *AddRequest) (*Item, error) {
+	return client.addTyped(context.Background(), req)
+}
+
+// QuickAdd saves itemUrl without a title or tags and returns the item pocket
+// resolved it to, like AddTyped.
+func (client *Client) QuickAdd(ctx context.Context, itemUrl string) (*Item, error) {
+	return client.addTyped(ctx, new(AddRequest).SetUrl(itemUrl))
+}
+
+func (client *Client) addTyped(ctx context.Context, req *AddRequest) (*Item, error) {
+	respBytes, err := client.add(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var r struct {
+		Item json.RawMessage `json:"item"`
+	}
+	if err := json.Unmarshal(respBytes, &r); err != nil {
+		return nil, fmt.Errorf("Error parsing http response: %s", err)
+	}
+	item, err := decodeItem(r.Item)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing http response: %s", err)
+	}
+	if item == nil {
+		return nil, fmt.Errorf("Error parsing http response: no item in %s", respBytes)
+	}
+	return item, nil
+}
+
+// decodeItem parses an item in any of the places pocket sends one: the list
+// of a retrieve response, the item of an add response or the result of an
+// add action. It returns nil for null or false, which pocket sends in place
+// of an item that doesn't exist or failed to add.
+func decodeItem(raw json.RawMessage) (*Item, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) || bytes.Equal(raw, []byte("false")) {
+		return nil, nil
+	}
+	item := new(Item)
+	if err := json.Unmarshal(raw, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// listenWordsPerMinute is the speaking rate used to estimate audio length
+// from the word count when pocket has no estimate of its own.
+const listenWordsPerMinute int = 155
+
+// ListenInfo groups what an audio-reading app needs to know about an item.
+type ListenInfo struct {
+	WordCount int
+	// AudioLength is pocket's listen duration estimate, or one derived from
+	// the word count when pocket didn't send it. It is 0 if neither is known.
+	AudioLength time.Duration
+}
+
+func (item *Item) ListenInfo() ListenInfo {
+	info := ListenInfo{WordCount: item.WordCount}
+	if item.ListenDurationEstimate > 0 {
+		info.AudioLength = time.Duration(item.ListenDurationEstimate) * time.Second
+	} else if item.WordCount > 0 {
+		info.AudioLength = time.Duration(item.WordCount) * time.Minute / time.Duration(listenWordsPerMinute)
+	}
+	return info
+}
+
+// FilterByWordCount returns the items whose word count is between min and
+// max inclusive, e.g. to pick quick reads. A max of 0 means no upper bound.
+// Pocket sends a word count of 0 when it doesn't know it, so with a min set
+// those items are left out.
+func FilterByWordCount(items []Item, min, max int) []Item {
+	var kept []Item
+	for _, item := range items {
+		if item.WordCount < min || (min > 0 && item.WordCount == 0) {
+			continue
+		}
+		if max > 0 && item.WordCount > max {
+			continue
+		}
+		kept = append(kept, item)
+	}
+	return kept
+}
+
+// GroupByDomain groups the items by the host of their resolved url (the given
+// one if pocket didn't resolve it), lowercased. Items whose url can't be
+// parsed or has no host are grouped under "".
+func GroupByDomain(items []Item) map[string][]Item {
+	groups := make(map[string][]Item)
+	for _, item := range items {
+		host := item.host()
+		groups[host] = append(groups[host], item)
+	}
+	return groups
+}
+
+// CollectDomains counts the items per domain, grouping them as GroupByDomain
+// does.
+func CollectDomains(items []Item) map[string]int {
+	counts := make(map[string]int)
+	for _, item := range items {
+		counts[item.host()]++
+	}
+	return counts
+}
+
+func (item *Item) host() string {
+	u, err := url.Parse(item.bestUrl())
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+func (item *Item) bestTitle() string {
+	if len(item.ResolvedTitle) > 0 {
+		return item.ResolvedTitle
+	}
+	return item.GivenTitle
+}
+
+// keyedValues returns the values of a collection pocket sends either as an
+// object keyed by id (ordered here by id) or as an array; an empty array,
+// null or a missing field give nil.
+func keyedValues(raw json.RawMessage) ([]json.RawMessage, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+	if raw[0] == '[' {
+		var l []json.RawMessage
+		err := json.Unmarshal(raw, &l)
+		return l, err
+	}
+
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if ni, nj := atoi(keys[i]), atoi(keys[j]); ni != nj {
+			return ni < nj
+		}
+		return keys[i] < keys[j]
+	})
+	l := make([]json.RawMessage, 0, len(keys))
+	for _, k := range keys {
+		l = append(l, m[k])
+	}
+	return l, nil
+}
+
+// decodeTags reads the tags of an item, which pocket sends either as an
+// object keyed by tag name or as an array, with every tag either a
+// {"item_id", "tag"} object or just the name.
+func decodeTags(raw json.RawMessage) ([]string, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+
+	var values []json.RawMessage
+	if raw[0] == '[' {
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return nil, err
+		}
+	} else {
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return nil, err
+		}
+		for _, v := range m {
+			values = append(values, v)
+		}
+	}
+
+	var tags []string
+	for _, v := range values {
+		var tag string
+		if v = bytes.TrimSpace(v); len(v) > 0 && v[0] == '{' {
+			var tj tagJson
+			if err := json.Unmarshal(v, &tj); err != nil {
+				return nil, err
+			}
+			tag = string(tj.Tag)
+		} else {
+			var s flexString
+			if err := json.Unmarshal(v, &s); err != nil {
+				return nil, err
+			}
+			tag = string(s)
+		}
+		if len(tag) > 0 {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// flexString decodes any JSON scalar into its string form: strings as is,
+// numbers as written, booleans as "1"/"0" and null as "".
+type flexString string
+
+func (s *flexString) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	switch {
+	case bytes.Equal(b, []byte("null")):
+		*s = ""
+	case bytes.Equal(b, []byte("true")):
+		*s = "1"
+	case bytes.Equal(b, []byte("false")):
+		*s = "0"
+	case len(b) > 0 && b[0] == '"':
+		var str string
+		if err := json.Unmarshal(b, &str); err != nil {
+			return err
+		}
+		*s = flexString(str)
+	default:
+		var n json.Number
+		if err := json.Unmarshal(b, &n); err != nil {
+			return err
+		}
+		*s = flexString(n)
+	}
+	return nil
+}
+
+// flexInt decodes a number pocket sent either as a JSON number or as a
+// string. Like flexString it accepts any scalar: null, "" and anything else
+// that isn't a number give 0, booleans 1 or 0, and fractions are truncated.
+type flexInt int64
+
+func (n *flexInt) UnmarshalJSON(b []byte) error {
+	var s flexString
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if i, err := strconv.ParseInt(strings.TrimSpace(string(s)), 10, 64); err == nil {
+		*n = flexInt(i)
+	} else if f, err := strconv.ParseFloat(strings.TrimSpace(string(s)), 64); err == nil {
+		*n = flexInt(f)
+	} else {
+		*n = 0
+	}
+	return nil
+}
+
+// atoi parses a number pocket sent as a string, treating anything
+// unparseable (including "") as 0.
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,372 @@
+package pocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestAddTyped(t *testing.T) {
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":1,"item":{"item_id":"402682570","normal_url":"http://example.com",`+
+			`"resolved_id":"402682570","resolved_url":"https://example.com/","title":"Example Domain",`+
+			`"excerpt":"For examples.","word_count":"120","has_image":"0","lang":"en",`+
+			`"authors":[],"images":[],"videos":[],"given_url":"http://example.com"}}`)
+	})
+
+	item, err := client.AddTyped(new(AddRequest).SetUrl("http://example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.ItemID != "402682570" || item.ResolvedURL != "https://example.com/" ||
+		item.ResolvedTitle != "Example Domain" || item.WordCount != 120 || item.Lang != "en" ||
+		item.GivenURL != "http://example.com" {
+		t.Errorf("got %+v", item)
+	}
+}
+
+// FuzzItemUnmarshal feeds arbitrary JSON to the item decoders, which must
+// never panic. The seed corpus in testdata/fuzz/FuzzItemUnmarshal holds items
+// as pocket sends them from the retrieve and add endpoints, with numbers
+// encoded as strings, numbers, nulls and the like.
+func FuzzItemUnmarshal(f *testing.F) {
+	f.Add([]byte(`{"item_id":"229279689","resolved_id":"229279689","word_count":"3197","time_added":"1346715015"}`))
+	f.Add([]byte(`{"item_id":229279689,"word_count":null,"favorite":true,"sort_id":"3"}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var item Item
+		if err := json.Unmarshal(data, &item); err == nil {
+			if !sort.StringsAreSorted(item.Tags) {
+				t.Errorf("tags %q aren't sorted", item.Tags)
+			}
+			item.Thumbnail()
+			item.ListenInfo()
+			item.Resolved()
+			item.DecodedTitle()
+		}
+
+		decodeItem(data)
+
+		var resp RetrieveResponse
+		if err := json.Unmarshal(data, &resp); err == nil {
+			resp.SortedItems()
+		}
+	})
+}
+
+func TestItemUnmarshalMixedEncodings(t *testing.T) {
+	var item Item
+	err := json.Unmarshal([]byte(`{"item_id":229279689,"word_count":null,"favorite":true,`+
+		`"sort_id":"3","time_added":1346715015,"status":"1","has_image":1.0,"excerpt":null}`), &item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Item{ItemID: "229279689", Favorite: true, SortID: 3, TimeAdded: 1346715015,
+		Status: StatusArchived, HasImage: 1, hasSortId: true}
+	if !reflect.DeepEqual(item, want) {
+		t.Errorf("got %+v, want %+v", item, want)
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"top image",
+			`{"item_id":"1","top_image_url":"https://example.com/top.png",` +
+				`"images":{"1":{"image_id":"1","src":"https://example.com/1.png"}}}`,
+			"https://example.com/top.png"},
+		{"first image",
+			`{"item_id":"1","images":{"1":{"image_id":"1","src":"https://example.com/1.png"},` +
+				`"2":{"image_id":"2","src":"https://example.com/2.png"}}}`,
+			"https://example.com/1.png"},
+		{"no images", `{"item_id":"1"}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var item Item
+			if err := json.Unmarshal([]byte(tt.json), &item); err != nil {
+				t.Fatal(err)
+			}
+			if got := item.Thumbnail(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListenInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		item Item
+		want ListenInfo
+	}{
+		{"pocket's estimate", Item{WordCount: 1550, ListenDurationEstimate: 600},
+			ListenInfo{WordCount: 1550, AudioLength: 10 * time.Minute}},
+		{"from word count", Item{WordCount: 1550},
+			ListenInfo{WordCount: 1550, AudioLength: 10 * time.Minute}},
+		{"unknown", Item{}, ListenInfo{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.item.ListenInfo(); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodedTitle(t *testing.T) {
+	tests := []struct {
+		item Item
+		want string
+	}{
+		{Item{ResolvedTitle: "Tom &amp; Jerry", GivenTitle: "given"}, "Tom & Jerry"},
+		{Item{GivenTitle: "&lt;b&gt; &#39;quoted&#39;"}, "<b> 'quoted'"},
+		{Item{ResolvedTitle: "Plain"}, "Plain"},
+	}
+	for _, tt := range tests {
+		if got := tt.item.DecodedTitle(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestFilterByWordCount(t *testing.T) {
+	items := []Item{
+		{ItemID: "unknown"},
+		{ItemID: "short", WordCount: 200},
+		{ItemID: "medium", WordCount: 1500},
+		{ItemID: "long", WordCount: 8000},
+	}
+	tests := []struct {
+		min, max int
+		want     []string
+	}{
+		{0, 1000, []string{"unknown", "short"}},
+		{100, 2000, []string{"short", "medium"}},
+		{1000, 0, []string{"medium", "long"}},
+		{0, 0, []string{"unknown", "short", "medium", "long"}},
+	}
+	for _, tt := range tests {
+		if got := itemIdsOf(FilterByWordCount(items, tt.min, tt.max)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d-%d: got %v, want %v", tt.min, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestQuickAdd(t *testing.T) {
+	p := newFakePocket(t)
+	item, err := p.client().QuickAdd(context.Background(), "https://example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.ItemID != "1001" || item.GivenURL != "https://example.com/a" {
+		t.Errorf("got %+v", item)
+	}
+	for _, key := range []string{"title", "tags", "tweet_id"} {
+		if _, ok := p.adds[0][key]; ok {
+			t.Errorf("sent %s: %v", key, p.adds[0])
+		}
+	}
+}
+
+func TestItemTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tags string
+		want []string
+	}{
+		{"object", `{"web":{"item_id":"1","tag":"web"},"go":{"item_id":"1","tag":"go"}}`, []string{"go", "web"}},
+		{"array of objects", `[{"item_id":"1","tag":"web"},{"item_id":"1","tag":"go"}]`, []string{"go", "web"}},
+		{"array of names", `["web","go"]`, []string{"go", "web"}},
+		{"empty array", `[]`, nil},
+		{"null", `null`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var item Item
+			if err := json.Unmarshal([]byte(`{"item_id":"1","tags":`+tt.tags+`}`), &item); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(item.Tags, tt.want) {
+				t.Errorf("got %q, want %q", item.Tags, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecognized(t *testing.T) {
+	tests := []struct {
+		item string
+		want bool
+	}{
+		{`{"item_id":"1","resolved_id":"1","normal_url":"http://example.com"}`, true},
+		{`{"item_id":"1","resolved_id":"0","normal_url":"http://example.com"}`, false},
+		{`{"item_id":"1","resolved_id":"1"}`, false},
+	}
+	for _, tt := range tests {
+		client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"status":1,"item":%s}`, tt.item)
+		})
+		item, err := client.AddTyped(new(AddRequest).SetUrl("http://example.com"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.Recognized != tt.want {
+			t.Errorf("%s: got Recognized %v, want %v", tt.item, item.Recognized, tt.want)
+		}
+	}
+}
+
+func TestHasCompleteDetail(t *testing.T) {
+	tests := []struct {
+		json string
+		want bool
+	}{
+		{`{"item_id":"1","resolved_title":"Simple","word_count":"10"}`, false},
+		{`{"item_id":"1","authors":{"1":{"author_id":"1","name":"Jane"}}}`, true},
+		{`{"item_id":"1","images":{"1":{"image_id":"1","src":"https://example.com/1.png"}}}`, true},
+		{`{"item_id":"1","tags":{"go":{"item_id":"1","tag":"go"}}}`, true},
+		{`{"item_id":"1","domain_metadata":{"name":"Example"}}`, true},
+	}
+	for _, tt := range tests {
+		var item Item
+		if err := json.Unmarshal([]byte(tt.json), &item); err != nil {
+			t.Fatal(err)
+		}
+		if got := item.HasCompleteDetail(); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.json, got, tt.want)
+		}
+	}
+}
+
+func TestResolved(t *testing.T) {
+	tests := []struct {
+		json string
+		want bool
+	}{
+		{`{"item_id":"1","resolved_id":"1"}`, true},
+		{`{"item_id":"1","resolved_id":"1","response_code":"200"}`, true},
+		{`{"item_id":"1","resolved_id":"1","response_code":"301"}`, true},
+		{`{"item_id":"1","resolved_id":"1","response_code":"404"}`, false},
+		{`{"item_id":"1","resolved_id":"0"}`, false},
+		{`{"item_id":"1"}`, false},
+	}
+	for _, tt := range tests {
+		var item Item
+		if err := json.Unmarshal([]byte(tt.json), &item); err != nil {
+			t.Fatal(err)
+		}
+		if got := item.Resolved(); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.json, got, tt.want)
+		}
+	}
+}
+
+func TestFlexInt(t *testing.T) {
+	tests := []struct {
+		field string
+		want  int
+	}{
+		{`,"word_count":120`, 120},
+		{`,"word_count":"120"`, 120},
+		{`,"word_count":" 120 "`, 120},
+		{`,"word_count":0`, 0},
+		{`,"word_count":"0"`, 0},
+		{`,"word_count":null`, 0},
+		{``, 0},
+		{`,"word_count":""`, 0},
+		{`,"word_count":"n/a"`, 0},
+		{`,"word_count":120.7`, 120},
+		{`,"word_count":true`, 1},
+	}
+	for _, tt := range tests {
+		var item Item
+		if err := json.Unmarshal([]byte(`{"item_id":"1"`+tt.field+`}`), &item); err != nil {
+			t.Errorf("%s: %v", tt.field, err)
+			continue
+		}
+		if item.WordCount != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.field, item.WordCount, tt.want)
+		}
+	}
+}
+
+func TestDecodeItem(t *testing.T) {
+	shapes := map[string]string{
+		// the item of an add response or the result of an add action
+		"add": `{"item_id":"1","resolved_id":"1","normal_url":"http://example.com",` +
+			`"resolved_url":"https://example.com/","title":"Example","word_count":"120","authors":[]}`,
+		// an item in the list of a retrieve response
+		"retrieve": `{"item_id":"1","resolved_id":"1","given_url":"http://example.com",` +
+			`"resolved_url":"https://example.com/","resolved_title":"Example","word_count":120,"sort_id":0}`,
+	}
+	for name, shape := range shapes {
+		item, err := decodeItem(json.RawMessage(shape))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if item.ItemID != "1" || item.ResolvedTitle != "Example" || item.WordCount != 120 ||
+			item.ResolvedURL != "https://example.com/" {
+			t.Errorf("%s: got %+v", name, item)
+		}
+	}
+
+	for _, raw := range []string{"", "null", "false", " null "} {
+		if item, err := decodeItem(json.RawMessage(raw)); item != nil || err != nil {
+			t.Errorf("%q: got %+v, %v, want nil", raw, item, err)
+		}
+	}
+	if _, err := decodeItem(json.RawMessage(`"item"`)); err == nil {
+		t.Error("a string: got no error")
+	}
+}
+
+func TestSortID(t *testing.T) {
+	tests := []struct {
+		field   string
+		want    int
+		present bool
+	}{
+		{`,"sort_id":3`, 3, true},
+		{`,"sort_id":"3"`, 3, true},
+		{`,"sort_id":0`, 0, true},
+		{``, 0, false},
+	}
+	for _, tt := range tests {
+		var item Item
+		if err := json.Unmarshal([]byte(`{"item_id":"1"`+tt.field+`}`), &item); err != nil {
+			t.Fatal(err)
+		}
+		if item.SortID != tt.want || item.hasSortId != tt.present {
+			t.Errorf("%s: got %d (present %v), want %d (%v)", tt.field, item.SortID, item.hasSortId, tt.want, tt.present)
+		}
+	}
+}
+
+func TestCollectDomains(t *testing.T) {
+	items := []Item{
+		{ItemID: "1", ResolvedURL: "https://Example.com/a", GivenURL: "https://t.co/x"},
+		{ItemID: "2", GivenURL: "https://example.com:8080/b"},
+		{ItemID: "3", ResolvedURL: "https://blog.example.org/c"},
+		{ItemID: "4", GivenURL: "http://[::1"},
+		{ItemID: "5"},
+	}
+	want := map[string]int{"example.com": 2, "blog.example.org": 1, "": 2}
+	if got := CollectDomains(items); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	groups := GroupByDomain(items)
+	if got := itemIdsOf(groups["example.com"]); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Errorf("grouped %v under example.com", got)
+	}
+}
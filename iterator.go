@@ -0,0 +1,256 @@
+package pocket
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrTooManyItems is returned by RetrieveAllItems when the request matches
+// more items than the cap set with WithMaxRetrieveItems.
+var ErrTooManyItems = errors.New("too many items to retrieve")
+
+const defaultPageSize int = 30
+
+// ItemIterator pages through the results of a RetrieveRequest, fetching
+// PageSize items per request and managing the offset itself. Any Count or
+// Offset set on the request is overridden for the pages, without changing
+// the request itself, so several iterators can share one base request.
+//
+// If the list changes while paging, pocket can return an item again on a
+// later page. The iterator only yields the first copy of each item id.
+//
+// Callers should defer Close so that stopping early releases the buffered
+// page and cancels a fetch that is still in flight.
+//
+//	it := client.Iterate(ctx, pocket.NewRetrieveRequest())
+//	defer it.Close()
+//	for it.Next() {
+//		item := it.Item()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type ItemIterator struct {
+	client   *Client
+	ctx      context.Context
+	cancel   context.CancelFunc
+	req      *RetrieveRequest
+	pageSize int
+	offset   int
+	page     []Item
+	item     Item
+	done     bool
+	err      error
+	limit    int
+	yielded  int
+	reported int
+
+	// seen holds the ids yielded so far; nil disables deduplication
+	seen map[string]bool
+}
+
+func (client *Client) Iterate(ctx context.Context, req *RetrieveRequest) *ItemIterator {
+	it := client.iterate(ctx, req)
+	it.seen = make(map[string]bool)
+	return it
+}
+
+// Next advances to the next item, fetching another page when needed. It
+// returns false when there are no more items or an error occurred.
+func (it *ItemIterator) Next() bool {
+	if it.limit > 0 && it.yielded >= it.limit {
+		return false
+	}
+	for len(it.page) == 0 {
+		if it.err == nil && it.req.onOffset != nil && it.offset != it.reported {
+			it.reported = it.offset
+			it.req.onOffset(it.offset)
+		}
+		if it.done || it.err != nil {
+			return false
+		}
+		it.fetch()
+	}
+	it.item, it.page = it.page[0], it.page[1:]
+	it.yielded++
+	return true
+}
+
+func (it *ItemIterator) Item() Item {
+	return it.item
+}
+
+func (it *ItemIterator) Err() error {
+	return it.err
+}
+
+// Close stops the iteration; Next returns false afterwards. It is safe to
+// call Close more than once.
+func (it *ItemIterator) Close() error {
+	it.cancel()
+	it.page = nil
+	it.done = true
+	return nil
+}
+
+// RetrieveAllItems pages through every item matching req and returns them
+// in the order pocket returned them. If the client has a cap set with
+// WithMaxRetrieveItems and more items match, it stops and returns the items
+// collected so far along with ErrTooManyItems. With WithRetrieveConcurrency,
+// several pages are fetched at once.
+//
+// An item returned more than once (because the list changed while paging)
+// appears once, at its first position, with the data of the copy that has
+// the latest time_updated.
+func (client *Client) RetrieveAllItems(ctx context.Context, req *RetrieveRequest) ([]Item, error) {
+	if req == nil {
+		return nil, ErrNilRequest
+	}
+	if client.retrieveWorkers > 1 {
+		return client.retrieveAllConcurrently(ctx, req)
+	}
+
+	c := client.newCollector()
+	it := client.iterate(ctx, req)
+	defer it.Close()
+	for it.Next() {
+		if err := c.add(it.Item()); err != nil {
+			return c.items, err
+		}
+	}
+	return c.items, it.Err()
+}
+
+// private methods
+
+func (client *Client) iterate(ctx context.Context, req *RetrieveRequest) *ItemIterator {
+	ctx, cancel := context.WithCancel(client.withRetryBudget(ctx))
+	if req == nil {
+		// fails on the first call to Next, like any other bad request
+		return &ItemIterator{client: client, ctx: ctx, cancel: cancel, err: ErrNilRequest}
+	}
+	return &ItemIterator{client: client, ctx: ctx, cancel: cancel, req: req, pageSize: req.effectivePageSize(),
+		limit: req.limit, offset: req.startOffset, reported: req.startOffset}
+}
+
+// itemCollector accumulates items for RetrieveAllItems, keeping one copy per
+// item id and enforcing the client's cap on the number of items.
+type itemCollector struct {
+	items []Item
+	index map[string]int
+	max   int
+}
+
+func (client *Client) newCollector() *itemCollector {
+	return &itemCollector{index: make(map[string]int), max: client.maxRetrieveItems}
+}
+
+func (c *itemCollector) add(item Item) error {
+	if i, ok := c.index[item.ItemID]; ok {
+		if item.TimeUpdated > c.items[i].TimeUpdated {
+			c.items[i] = item
+		}
+		return nil
+	}
+	if c.max > 0 && len(c.items) == c.max {
+		return ErrTooManyItems
+	}
+	c.index[item.ItemID] = len(c.items)
+	c.items = append(c.items, item)
+	return nil
+}
+
+func (req *RetrieveRequest) effectivePageSize() int {
+	if req.pageSize > 0 {
+		return req.pageSize
+	}
+	return defaultPageSize
+}
+
+// retrieveAllConcurrently fetches pages in windows of retrieveWorkers pages
+// at a time. Every page is fetched from its own copy of req at an offset
+// fixed up front, so pages never overlap, and the results are merged in
+// offset order. The first short page ends the list: anything fetched past it
+// in the same window is ignored.
+func (client *Client) retrieveAllConcurrently(ctx context.Context, req *RetrieveRequest) ([]Item, error) {
+	n := client.retrieveWorkers
+	pageSize := req.effectivePageSize()
+	ctx = client.withRetryBudget(ctx)
+
+	c := client.newCollector()
+	for offset := req.startOffset; ; offset += n * pageSize {
+		pages := make([][]Item, n)
+		errs := make([]error, n)
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			pageReq := req.Clone().Count(pageSize).Offset(offset + i*pageSize)
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				resp, err := client.retrieveTyped(ctx, pageReq)
+				if err != nil {
+					errs[i] = err
+					return
+				}
+				pages[i] = resp.Items()
+			}(i)
+		}
+		wg.Wait()
+
+		for i := 0; i < n; i++ {
+			if errs[i] != nil {
+				return c.items, errs[i]
+			}
+			for j := range pages[i] {
+				if !req.keep(&pages[i][j]) {
+					continue
+				}
+				if err := c.add(pages[i][j]); err != nil {
+					return c.items, err
+				}
+				if req.limit > 0 && len(c.items) == req.limit {
+					return c.items, nil
+				}
+			}
+			if req.onOffset != nil && len(pages[i]) > 0 {
+				req.onOffset(offset + i*pageSize + len(pages[i]))
+			}
+			if len(pages[i]) < pageSize {
+				return c.items, nil
+			}
+		}
+	}
+}
+
+func (it *ItemIterator) fetch() {
+	// page on a copy so that the base request can be shared
+	pageReq := it.req.Clone().Count(it.pageSize).Offset(it.offset)
+	resp, err := it.client.retrieveTyped(it.ctx, pageReq)
+	if err != nil {
+		it.err = err
+		return
+	}
+
+	items := resp.Items()
+	it.offset += len(items)
+	if len(items) < it.pageSize {
+		it.done = true
+	}
+
+	// filter after paging so that the offset matches what pocket returned
+	it.page = items[:0]
+	for i := range items {
+		if !it.req.keep(&items[i]) {
+			continue
+		}
+		if it.seen != nil {
+			if it.seen[items[i].ItemID] {
+				continue
+			}
+			it.seen[items[i].ItemID] = true
+		}
+		it.page = append(it.page, items[i])
+	}
+}
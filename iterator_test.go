@@ -0,0 +1,226 @@
+package pocket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestIteratorPageSize(t *testing.T) {
+	p := newFakePocket(t, numberedItems(7)...)
+	it := p.client().Iterate(context.Background(), NewRetrieveRequest().PageSize(3))
+	defer it.Close()
+
+	var items []Item
+	for it.Next() {
+		items = append(items, it.Item())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 7 {
+		t.Errorf("got %d items, want 7", len(items))
+	}
+
+	var pages []string
+	for _, params := range p.retrieves {
+		pages = append(pages, params["count"]+"@"+params["offset"])
+	}
+	if want := []string{"3@0", "3@3", "3@6"}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("fetched pages %v, want %v", pages, want)
+	}
+}
+
+func TestIteratorClose(t *testing.T) {
+	p := newFakePocket(t, numberedItems(6)...)
+	it := p.client().Iterate(context.Background(), NewRetrieveRequest().PageSize(2))
+	if !it.Next() {
+		t.Fatal(it.Err())
+	}
+	if err := it.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if it.Next() {
+		t.Error("Next returned true after Close")
+	}
+	if it.page != nil || it.ctx.Err() == nil {
+		t.Error("Close kept the page or the context alive")
+	}
+	if len(p.retrieves) != 1 {
+		t.Errorf("fetched %d pages, want 1", len(p.retrieves))
+	}
+	if err := it.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestRetrieveAllItems(t *testing.T) {
+	p := newFakePocket(t, numberedItems(5)...)
+	items, err := p.client().RetrieveAllItems(context.Background(), NewRetrieveRequest().PageSize(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := itemIdsOf(items), []string{"1", "2", "3", "4", "5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if len(p.retrieves) != 2 {
+		t.Errorf("fetched %d pages, want 2", len(p.retrieves))
+	}
+}
+
+func TestRetrieveAllItemsCap(t *testing.T) {
+	p := newFakePocket(t, numberedItems(5)...)
+	client := p.client(WithMaxRetrieveItems(4))
+	items, err := client.RetrieveAllItems(context.Background(), NewRetrieveRequest().PageSize(3))
+	if err != ErrTooManyItems {
+		t.Errorf("got %v, want ErrTooManyItems", err)
+	}
+	if len(items) != 4 {
+		t.Errorf("got %d items, want the 4 collected", len(items))
+	}
+}
+
+func TestRetrieveAllItemsConcurrently(t *testing.T) {
+	for _, n := range []int{25, 24, 0} {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			p := newFakePocket(t, numberedItems(n)...)
+			client := p.client(WithRetrieveConcurrency(3))
+			items, err := client.RetrieveAllItems(context.Background(), NewRetrieveRequest().PageSize(5))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := make([]string, n)
+			for i := range want {
+				want[i] = strconv.Itoa(i + 1)
+			}
+			if got := itemIdsOf(items); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+
+			offsets := make(map[string]bool)
+			for _, params := range p.retrieves {
+				if params["count"] != "5" || offsets[params["offset"]] {
+					t.Errorf("fetched %v twice or with the wrong page size", params)
+				}
+				offsets[params["offset"]] = true
+			}
+		})
+	}
+}
+
+func TestRetrieveAllItemsOverlappingPages(t *testing.T) {
+	// item 2 moved down a position between the first and second page
+	pages := map[string]string{
+		"0": `{"1":{"item_id":"1","sort_id":0},"2":{"item_id":"2","sort_id":1,"time_updated":"1"}}`,
+		"2": `{"2":{"item_id":"2","sort_id":0,"time_updated":"5"},"3":{"item_id":"3","sort_id":1}}`,
+		"4": `{"4":{"item_id":"4","sort_id":0}}`,
+	}
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"status":1,"list":%s}`, pages[requestParams(t, r)["offset"]])
+	})
+
+	items, err := client.RetrieveAllItems(context.Background(), NewRetrieveRequest().PageSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := itemIdsOf(items), []string{"1", "2", "3", "4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if items[1].TimeUpdated != 5 {
+		t.Errorf("kept item 2 updated at %d, want the newest copy", items[1].TimeUpdated)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	p := newFakePocket(t, numberedItems(10)...)
+	client := p.client()
+	items, err := client.RetrieveAllItems(context.Background(), NewRetrieveRequest().PageSize(3).Limit(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := itemIdsOf(items), []string{"1", "2", "3", "4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if len(p.retrieves) != 2 {
+		t.Errorf("fetched %d pages, want 2", len(p.retrieves))
+	}
+
+	it := client.Iterate(context.Background(), NewRetrieveRequest().PageSize(3).Limit(2))
+	defer it.Close()
+	n := 0
+	for it.Next() {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("iterated %d items, want 2", n)
+	}
+}
+
+func TestResumeAt(t *testing.T) {
+	p := newFakePocket(t, numberedItems(10)...)
+	client := p.client()
+
+	// the export crashes while handling the 7th item
+	saved := 0
+	it := client.Iterate(context.Background(), NewRetrieveRequest().PageSize(3).OnOffset(func(offset int) {
+		saved = offset
+	}))
+	for i := 0; i < 7 && it.Next(); i++ {
+	}
+	it.Close()
+	if saved != 6 {
+		t.Fatalf("saved offset %d, want 6", saved)
+	}
+
+	var offsets []int
+	items, err := client.RetrieveAllItems(context.Background(),
+		NewRetrieveRequest().PageSize(3).ResumeAt(saved).OnOffset(func(offset int) {
+			offsets = append(offsets, offset)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := itemIdsOf(items), []string{"7", "8", "9", "10"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resumed with %v, want %v", got, want)
+	}
+	if want := []int{9, 10}; !reflect.DeepEqual(offsets, want) {
+		t.Errorf("reported offsets %v, want %v", offsets, want)
+	}
+}
+
+func TestIterateSharedRequest(t *testing.T) {
+	p := newFakePocket(t, numberedItems(20)...)
+	client := p.client()
+	base := NewRetrieveRequest().OnlyTag("go").PageSize(3)
+
+	var wg sync.WaitGroup
+	counts := make([]int, 8)
+	for i := range counts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			it := client.Iterate(context.Background(), base)
+			defer it.Close()
+			for it.Next() {
+				counts[i]++
+			}
+			if err := it.Err(); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i, n := range counts {
+		if n != 20 {
+			t.Errorf("iterator %d saw %d items, want 20", i, n)
+		}
+	}
+	if want := map[string]string{"tag": "go"}; !reflect.DeepEqual(base.params, want) {
+		t.Errorf("base request changed to %v", base.params)
+	}
+}
@@ -0,0 +1,133 @@
+package pocket
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AccountLimits holds pocket's rate limits as reported in the X-Limit-*
+// response headers. There are separate limits per user and per consumer
+// key; the reset durations are the time left until the limit resets.
+type AccountLimits struct {
+	UserLimit     int
+	UserRemaining int
+	UserReset     time.Duration
+	KeyLimit      int
+	KeyRemaining  int
+	KeyReset      time.Duration
+}
+
+// Limits makes the smallest possible retrieve call and returns the rate
+// limits reported with it. The call itself counts against the limits.
+func (client *Client) Limits(ctx context.Context) (*AccountLimits, error) {
+	if err := client.verifyCredentials(); err != nil {
+		return nil, err
+	}
+
+	params := map[string]string{
+		"consumer_key": client.ConsumerToken,
+		"access_token": client.accessToken(),
+		"count":        "1",
+		"detailType":   "simple",
+	}
+	_, header, err := client.doPostJson(ctx, retrieveUrl, params)
+	if err != nil {
+		return nil, err
+	}
+	return parseLimits(header), nil
+}
+
+// QuotaTracker accumulates the rate limit readings of every response a
+// client gets, so tools making many calls can watch their quota without
+// spending a call on Limits. It's safe for concurrent use.
+//
+//	tracker := client.QuotaTracker()
+//	tracker.Reset()
+//	... // a batch of calls
+//	user, key := tracker.Used()
+type QuotaTracker struct {
+	mu       sync.Mutex
+	latest   AccountLimits
+	seen     bool
+	userUsed int
+	keyUsed  int
+}
+
+// QuotaTracker returns the client's tracker, which is updated with every
+// response.
+func (client *Client) QuotaTracker() *QuotaTracker {
+	return &client.quota
+}
+
+// Quota is short for client.QuotaTracker().Latest().
+func (client *Client) Quota() AccountLimits {
+	return client.quota.Latest()
+}
+
+// QuotaUsed is short for client.QuotaTracker().Used().
+func (client *Client) QuotaUsed() (user, key int) {
+	return client.quota.Used()
+}
+
+// Latest returns the rate limits reported with the most recent response that
+// carried them. It's the zero value before the first one.
+func (q *QuotaTracker) Latest() AccountLimits {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.latest
+}
+
+// Used returns how much of the user and consumer key limits the client's
+// calls used up since it was created or the last Reset, going by the drops in
+// the remaining counts between responses. Calls made by other clients sharing
+// the limits count too.
+func (q *QuotaTracker) Used() (user, key int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.userUsed, q.keyUsed
+}
+
+// Reset sets the usage counted by Used back to zero, e.g. before a batch of
+// calls whose usage is wanted. The latest reading is kept, so the first
+// response after Reset already counts.
+func (q *QuotaTracker) Reset() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.userUsed, q.keyUsed = 0, 0
+}
+
+// private methods
+
+func (q *QuotaTracker) update(header http.Header) {
+	if len(header.Get("X-Limit-User-Remaining")) == 0 && len(header.Get("X-Limit-Key-Remaining")) == 0 {
+		return
+	}
+	limits := parseLimits(header)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	// a rise in the remaining count means the limit was reset in between
+	if q.seen && limits.UserRemaining < q.latest.UserRemaining {
+		q.userUsed += q.latest.UserRemaining - limits.UserRemaining
+	}
+	if q.seen && limits.KeyRemaining < q.latest.KeyRemaining {
+		q.keyUsed += q.latest.KeyRemaining - limits.KeyRemaining
+	}
+	q.latest, q.seen = *limits, true
+}
+
+func parseLimits(header http.Header) *AccountLimits {
+	seconds := func(key string) time.Duration {
+		return time.Duration(atoi(header.Get(key))) * time.Second
+	}
+	return &AccountLimits{
+		UserLimit:     atoi(header.Get("X-Limit-User-Limit")),
+		UserRemaining: atoi(header.Get("X-Limit-User-Remaining")),
+		UserReset:     seconds("X-Limit-User-Reset"),
+		KeyLimit:      atoi(header.Get("X-Limit-Key-Limit")),
+		KeyRemaining:  atoi(header.Get("X-Limit-Key-Remaining")),
+		KeyReset:      seconds("X-Limit-Key-Reset"),
+	}
+}
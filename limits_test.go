@@ -0,0 +1,139 @@
+package pocket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLimits(t *testing.T) {
+	var params map[string]string
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		params = requestParams(t, r)
+		h := w.Header()
+		h.Set("X-Limit-User-Limit", "320")
+		h.Set("X-Limit-User-Remaining", "319")
+		h.Set("X-Limit-User-Reset", "3600")
+		h.Set("X-Limit-Key-Limit", "10000")
+		h.Set("X-Limit-Key-Remaining", "9990")
+		h.Set("X-Limit-Key-Reset", "60")
+		fmt.Fprint(w, `{"status":1,"list":[]}`)
+	})
+
+	limits, err := client.Limits(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AccountLimits{UserLimit: 320, UserRemaining: 319, UserReset: time.Hour,
+		KeyLimit: 10000, KeyRemaining: 9990, KeyReset: time.Minute}
+	if *limits != want {
+		t.Errorf("got %+v, want %+v", *limits, want)
+	}
+	if params["count"] != "1" {
+		t.Errorf("retrieved with %v, want a single item", params)
+	}
+}
+
+func TestLimitsFormEncoding(t *testing.T) {
+	var contentType string
+	var params map[string]string
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		params = requestParams(t, r)
+		w.Header().Set("X-Limit-User-Remaining", "9")
+		fmt.Fprint(w, `{"status":1,"list":[]}`)
+	}, WithFormEncoding())
+
+	limits, err := client.Limits(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/x-www-form-urlencoded" {
+		t.Errorf("sent Content-Type %q, want a form", contentType)
+	}
+	if params["count"] != "1" || params["access_token"] != "access-token" {
+		t.Errorf("sent params %v", params)
+	}
+	if limits.UserRemaining != 9 {
+		t.Errorf("got %+v, want 9 remaining", limits)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	remaining := []struct{ user, key string }{{"100", "1000"}, {"98", "997"}, {"", ""}, {"320", "996"}}
+	call := 0
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		if rem := remaining[call]; len(rem.user) > 0 {
+			h.Set("X-Limit-User-Limit", "320")
+			h.Set("X-Limit-User-Remaining", rem.user)
+			h.Set("X-Limit-Key-Limit", "10000")
+			h.Set("X-Limit-Key-Remaining", rem.key)
+		}
+		call++
+		fmt.Fprint(w, `{"status":1,"list":[]}`)
+	})
+
+	if (client.Quota() != AccountLimits{}) {
+		t.Error("got a quota before any call")
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := client.Retrieve(NewRetrieveRequest()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if user, key := client.QuotaUsed(); user != 2 || key != 3 {
+		t.Errorf("got used %d/%d, want 2/3", user, key)
+	}
+	if q := client.Quota(); q.UserRemaining != 98 || q.KeyRemaining != 997 {
+		t.Errorf("a response without headers changed the quota to %+v", q)
+	}
+
+	// the user limit was reset in between
+	if _, err := client.Retrieve(NewRetrieveRequest()); err != nil {
+		t.Fatal(err)
+	}
+	if user, key := client.QuotaUsed(); user != 2 || key != 4 {
+		t.Errorf("after a reset got used %d/%d, want 2/4", user, key)
+	}
+	if q := client.Quota(); q.UserRemaining != 320 || q.UserLimit != 320 {
+		t.Errorf("got %+v, want the latest reading", q)
+	}
+}
+
+func TestQuotaTrackerReset(t *testing.T) {
+	remaining := 100
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Limit-User-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-Limit-Key-Remaining", strconv.Itoa(remaining*10))
+		remaining--
+		fmt.Fprint(w, `{"status":1,"list":[]}`)
+	})
+	tracker := client.QuotaTracker()
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.Retrieve(NewRetrieveRequest()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if user, key := tracker.Used(); user != 2 || key != 20 {
+		t.Errorf("got used %d/%d, want 2/20", user, key)
+	}
+
+	// a batch after Reset counts from the latest reading
+	tracker.Reset()
+	for i := 0; i < 2; i++ {
+		if _, err := client.Retrieve(NewRetrieveRequest()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if user, key := tracker.Used(); user != 2 || key != 20 {
+		t.Errorf("batch used %d/%d, want 2/20", user, key)
+	}
+	if latest := tracker.Latest(); latest != client.Quota() || latest.UserRemaining != 96 {
+		t.Errorf("latest reading %+v, want 96 remaining", latest)
+	}
+}
@@ -0,0 +1,589 @@
+package pocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoTags is returned by NewTagsReplaceAction when given no tags, and by
+// Modify when tag normalization leaves a tagging action without any. Replacing
+// an item's tags with nothing would clear them, which has to be asked for
+// explicitly with NewTagsClearAction.
+var ErrNoTags = errors.New("no tags given; use a tags_clear action to remove all tags")
+
+// ErrEmptyTag is returned by the calls that work on a single tag, MoveTag and
+// TagDomain, when given an empty one.
+var ErrEmptyTag = errors.New("empty tag")
+
+// ModifyResponse is the typed result of a modify call. ActionResults holds
+// one entry per action in the order they were sent: false for an action that
+// failed, true (or the added item for an add action) otherwise.
+type ModifyResponse struct {
+	Status        int           `json:"status"`
+	ActionResults []interface{} `json:"action_results"`
+}
+
+// addedItem decodes the result of the i-th action, an add action, into the
+// item it added; it returns nil if the action failed.
+func (resp *ModifyResponse) addedItem(i int) *Item {
+	if !resp.Succeeded(i) {
+		return nil
+	}
+	// the result of an add action is the item, in the usual encoding
+	b, err := json.Marshal(resp.ActionResults[i])
+	if err != nil {
+		return nil
+	}
+	item, err := decodeItem(b)
+	if err != nil {
+		return nil
+	}
+	return item
+}
+
+// Succeeded reports whether the i-th action of the batch was applied.
+func (resp *ModifyResponse) Succeeded(i int) bool {
+	if i < 0 || i >= len(resp.ActionResults) {
+		return false
+	}
+	switch r := resp.ActionResults[i].(type) {
+	case nil:
+		return false
+	case bool:
+		return r
+	default:
+		return true
+	}
+}
+
+// ModifyTyped sends the batch and returns the typed response. Actions that
+// fail are only reported in the response, unless the client was created with
+// WithFailOnActionError, in which case an *ActionsFailedError is returned
+// along with the response.
+func (client *Client) ModifyTyped(req *ModifyRequest) (*ModifyResponse, error) {
+	resp, err := client.modifyTyped(context.Background(), req)
+	if err != nil || !client.failOnActionError {
+		return resp, err
+	}
+
+	var failed []int
+	for i := range req.actions {
+		if !resp.Succeeded(i) {
+			failed = append(failed, i)
+		}
+	}
+	if len(failed) > 0 {
+		return resp, &ActionsFailedError{Failed: failed}
+	}
+	return resp, nil
+}
+
+// NewAction returns an action of any kind, including ones this package has no
+// constant for. params is copied, so the caller can keep changing its map
+// (e.g. to build the next action) without affecting the action.
+func NewAction(kind ActionKind, params map[string]string) Action {
+	return Action{Kind: kind, Params: copyParams(params)}
+}
+
+// NewTagsAddAction returns an action adding tags to an item. Adding no tags
+// is a no-op: AddAction skips the action, so it's never sent to pocket.
+func NewTagsAddAction(itemId string, tags []string) Action {
+	return Action{Kind: ActionTagsAdd, Params: map[string]string{"item_id": itemId, "tags": strings.Join(tags, ",")}}
+}
+
+// NewTagsReplaceAction returns an action replacing all of an item's tags. It
+// returns ErrNoTags if tags is empty.
+func NewTagsReplaceAction(itemId string, tags []string) (Action, error) {
+	if len(tags) == 0 {
+		return Action{}, ErrNoTags
+	}
+	return Action{Kind: ActionTagsReplace, Params: map[string]string{"item_id": itemId, "tags": strings.Join(tags, ",")}}, nil
+}
+
+// NewTagsClearAction returns an action removing all of an item's tags.
+func NewTagsClearAction(itemId string) Action {
+	return Action{Kind: ActionTagsClear, Params: map[string]string{"item_id": itemId}}
+}
+
+// TooManyActionsError is returned when a modify batch has more actions than
+// the client allows (see WithMaxActions).
+type TooManyActionsError struct {
+	Count int
+	Max   int
+}
+
+func (e *TooManyActionsError) Error() string {
+	return fmt.Sprintf("modify batch has %d actions, more than the maximum of %d; split it with ModifyChunked",
+		e.Count, e.Max)
+}
+
+// ActionsFailedError is returned by ModifyTyped with WithFailOnActionError
+// when some actions of the batch failed.
+type ActionsFailedError struct {
+	// Failed holds the indices of the actions pocket reported as failed.
+	Failed []int
+}
+
+func (e *ActionsFailedError) Error() string {
+	return fmt.Sprintf("actions %v failed", e.Failed)
+}
+
+// RollbackError is returned by ModifyAtomic when some actions of the batch
+// failed. It lists what was done to undo the actions that succeeded.
+type RollbackError struct {
+	// Failed holds the indices of the actions pocket reported as failed.
+	Failed []int
+	// RolledBack holds the reversing actions that were applied.
+	RolledBack []Action
+	// NotRolledBack holds succeeded actions that couldn't be reversed,
+	// either because they have no inverse or because the rollback failed.
+	NotRolledBack []Action
+	// Err is set when the rollback call itself failed.
+	Err error
+}
+
+func (e *RollbackError) Error() string {
+	msg := fmt.Sprintf("actions %v failed; rolled back %d, could not roll back %d",
+		e.Failed, len(e.RolledBack), len(e.NotRolledBack))
+	if e.Err != nil {
+		msg += fmt.Sprintf(": %s", e.Err)
+	}
+	return msg
+}
+
+// inverseActions maps the actions ModifyAtomic knows how to undo to their
+// inverse.
+var inverseActions = map[ActionKind]ActionKind{
+	ActionArchive:    ActionReadd,
+	ActionReadd:      ActionArchive,
+	ActionFavorite:   ActionUnfavorite,
+	ActionUnfavorite: ActionFavorite,
+}
+
+// ModifyAtomic emulates all-or-nothing semantics on top of Modify, which
+// pocket doesn't provide. If any action fails, the actions that succeeded
+// are reversed on a best-effort basis (archive/readd, favorite/unfavorite;
+// other kinds can't be undone) and a *RollbackError describing the outcome is
+// returned along with the original response.
+func (client *Client) ModifyAtomic(req *ModifyRequest) (*ModifyResponse, error) {
+	ctx := context.Background()
+	resp, err := client.modifyTyped(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	rbErr := new(RollbackError)
+	var succeeded []Action
+	for i, a := range req.actions {
+		if resp.Succeeded(i) {
+			succeeded = append(succeeded, a)
+		} else {
+			rbErr.Failed = append(rbErr.Failed, i)
+		}
+	}
+	if len(rbErr.Failed) == 0 {
+		return resp, nil
+	}
+
+	// undo in reverse order so the item ends up where it started
+	rollback := new(ModifyRequest)
+	for i := len(succeeded) - 1; i >= 0; i-- {
+		a := succeeded[i]
+		if kind, ok := inverseActions[a.Kind]; ok {
+			rollback.AddAction(Action{Kind: kind, Params: a.Params})
+		} else {
+			rbErr.NotRolledBack = append(rbErr.NotRolledBack, a)
+		}
+	}
+	if len(rollback.actions) == 0 {
+		return resp, rbErr
+	}
+
+	rbResp, err := client.modifyTyped(ctx, rollback)
+	for i, a := range rollback.actions {
+		if err == nil && rbResp.Succeeded(i) {
+			rbErr.RolledBack = append(rbErr.RolledBack, a)
+		} else {
+			rbErr.NotRolledBack = append(rbErr.NotRolledBack, a)
+		}
+	}
+	rbErr.Err = err
+	return resp, rbErr
+}
+
+// ModifyChunked sends the actions of req in batches of at most chunkSize
+// actions (the client's maximum if chunkSize is 0), one after the other, and
+// merges the results into a single response. If a batch fails or ctx ends
+// between batches, the results of the batches sent so far are returned along
+// with the error.
+func (client *Client) ModifyChunked(ctx context.Context, req *ModifyRequest, chunkSize int) (*ModifyResponse, error) {
+	return client.modifyChunked(ctx, req, chunkSize, nil)
+}
+
+// ModifyDependent sends a batch in which actions may refer to items added
+// earlier in the same batch. Pocket applies the actions of a batch in order,
+// but an action can only name its item by item_id, which isn't known until
+// the add went through. Here an action can name an item by its "url" param
+// instead of "item_id": when the url was added earlier in the batch, the
+// batch is split before the action, and the action is sent in a later call
+// with the item_id of the added item. The results of all calls are merged.
+// If a call fails, the results so far are returned along with the error.
+func (client *Client) ModifyDependent(ctx context.Context, req *ModifyRequest) (*ModifyResponse, error) {
+	if req == nil {
+		return nil, ErrNilRequest
+	}
+
+	merged := &ModifyResponse{Status: 1}
+	addedIds := make(map[string]string) // url -> item_id of items added so far
+	pending := make(map[string]int)     // url -> index in segment of adds not sent yet
+	var segment []Action
+	flush := func() error {
+		if len(segment) == 0 {
+			return nil
+		}
+		resp, err := client.ModifyChunked(ctx, &ModifyRequest{actions: segment}, 0)
+		if resp != nil {
+			if resp.Status != 1 {
+				merged.Status = resp.Status
+			}
+			merged.ActionResults = append(merged.ActionResults, resp.ActionResults...)
+			for itemUrl, i := range pending {
+				if item := resp.addedItem(i); item != nil {
+					addedIds[itemUrl] = item.ItemID
+				}
+			}
+		}
+		segment = nil
+		for k := range pending {
+			delete(pending, k)
+		}
+		return err
+	}
+
+	for _, a := range req.actions {
+		itemUrl := a.Params["url"]
+		if a.Kind != ActionAdd && len(a.Params["item_id"]) == 0 && len(itemUrl) > 0 {
+			if _, ok := pending[itemUrl]; ok {
+				if err := flush(); err != nil {
+					return merged, err
+				}
+			}
+			if id, ok := addedIds[itemUrl]; ok {
+				a.Params = copyParams(a.Params)
+				a.Params["item_id"] = id
+				delete(a.Params, "url")
+			}
+		}
+		if a.Kind == ActionAdd && len(itemUrl) > 0 {
+			pending[itemUrl] = len(segment)
+		}
+		segment = append(segment, a)
+	}
+	return merged, flush()
+}
+
+// AddBatchResult is the outcome of AddBatch.
+type AddBatchResult struct {
+	// Items holds the added items in the order of the requests, with nil for
+	// the urls that couldn't be added.
+	Items []*Item
+	// Failed lists the urls that pocket didn't add.
+	Failed []string
+}
+
+// AddBatch saves many urls with add actions sent in batches of at most
+// chunkSize (the client's maximum if chunkSize is 0), like ModifyChunked.
+// Urls pass through the WithURLResolver function like with Add; SaveOptions
+// are ignored. If a batch fails or ctx ends between batches, the results so
+// far (which only cover the urls sent) are returned along with the error.
+func (client *Client) AddBatch(ctx context.Context, reqs []*AddRequest, chunkSize int) (*AddBatchResult, error) {
+	return client.AddBatchProgress(ctx, reqs, chunkSize, nil)
+}
+
+// AddBatchProgress is AddBatch, calling progress after every batch with the
+// number of urls added and failed so far, e.g. to show the progress of a
+// large import.
+func (client *Client) AddBatchProgress(ctx context.Context,
+	reqs []*AddRequest, chunkSize int, progress func(succeeded, failed int)) (*AddBatchResult, error) {
+	urls := make([]string, len(reqs))
+	modifyReq := new(ModifyRequest)
+	for i, req := range reqs {
+		if req == nil {
+			return nil, ErrNilRequest
+		}
+		if err := req.Validate(); err != nil {
+			return nil, err
+		}
+		urls[i] = req.url
+		if client.urlResolver != nil {
+			resolved, err := client.urlResolver(ctx, req.url)
+			if err != nil {
+				return nil, fmt.Errorf("Error resolving url %s: %s", req.url, err)
+			}
+			urls[i] = resolved
+		}
+
+		params := map[string]string{"url": urls[i]}
+		if len(req.title) > 0 {
+			params["title"] = req.title
+		}
+		if tags := client.tagList(req.tags); len(tags) > 0 {
+			params["tags"] = strings.Join(tags, ",")
+		}
+		if len(req.tweetId) > 0 {
+			params["ref_id"] = req.tweetId
+		}
+		modifyReq.AddAction(Action{Kind: ActionAdd, Params: params})
+	}
+
+	var onChunk func(*ModifyResponse)
+	if progress != nil {
+		onChunk = func(merged *ModifyResponse) {
+			succeeded := 0
+			for i := range merged.ActionResults {
+				if merged.Succeeded(i) {
+					succeeded++
+				}
+			}
+			progress(succeeded, len(merged.ActionResults)-succeeded)
+		}
+	}
+	resp, err := client.modifyChunked(ctx, modifyReq, chunkSize, onChunk)
+	result := new(AddBatchResult)
+	if resp == nil {
+		return result, err
+	}
+	for i := range resp.ActionResults {
+		item := resp.addedItem(i)
+		result.Items = append(result.Items, item)
+		if item == nil {
+			result.Failed = append(result.Failed, urls[i])
+		}
+	}
+	return result, err
+}
+
+// FavoriteMany favorites every item in itemIds, batching the actions into as
+// few modify calls as the client's action limit allows.
+func (client *Client) FavoriteMany(ctx context.Context, itemIds []string) (*ModifyResponse, error) {
+	return client.modifyMany(ctx, ActionFavorite, itemIds)
+}
+
+// ArchiveMany archives every item in itemIds, batched like FavoriteMany.
+func (client *Client) ArchiveMany(ctx context.Context, itemIds []string) (*ModifyResponse, error) {
+	return client.modifyMany(ctx, ActionArchive, itemIds)
+}
+
+// DeleteMany deletes every item in itemIds, batched like FavoriteMany.
+func (client *Client) DeleteMany(ctx context.Context, itemIds []string) (*ModifyResponse, error) {
+	return client.modifyMany(ctx, ActionDelete, itemIds)
+}
+
+// ArchiveMatching archives every item matching req, paging through all of
+// them first and then archiving them like ArchiveMany. Items that are already
+// archived are left out of the batch.
+func (client *Client) ArchiveMatching(ctx context.Context, req *RetrieveRequest) (*ModifyResponse, error) {
+	return client.modifyMatching(ctx, ActionArchive, req, func(item Item) bool {
+		return item.Status != StatusArchived
+	})
+}
+
+// ReaddMatching moves the archived items matching req back to the unread
+// list. The request's state filter is replaced by StateArchive, on a copy so
+// that req itself is left as is.
+func (client *Client) ReaddMatching(ctx context.Context, req *RetrieveRequest) (*ModifyResponse, error) {
+	if req == nil {
+		return nil, ErrNilRequest
+	}
+	return client.modifyMatching(ctx, ActionReadd, req.Clone().OnlyState(StateArchive), nil)
+}
+
+// ClearFavorites unfavorites every favorited item (in any state), batched like
+// FavoriteMany. confirm is called with the number of favorites found before
+// anything is changed; if it returns false, nothing is, and ClearFavorites
+// returns an empty response like when there are no favorites. A nil confirm
+// doesn't ask.
+func (client *Client) ClearFavorites(ctx context.Context, confirm func(int) bool) (*ModifyResponse, error) {
+	itemIds, err := client.matchingIds(ctx, NewRetrieveRequest().OnlyState(StateAll).OnlyFavorited(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if confirm != nil && !confirm(len(itemIds)) {
+		return &ModifyResponse{Status: 1}, nil
+	}
+	return client.modifyMany(ctx, ActionUnfavorite, itemIds)
+}
+
+// MoveTag moves every item tagged fromTag (in any state) to toTag: it adds
+// toTag and removes fromTag on each item in one batch. A batch too large for
+// the client is split like ModifyChunked, but only between items, so that
+// both actions on an item are always sent together. Pocket's tag_rename
+// action does the same for the whole list at once, but can't be mixed with
+// other actions.
+//
+// The tags are normalized as configured with WithTagNormalization. It
+// returns ErrEmptyTag if either tag is empty, and an error if both are the
+// same tag, without retrieving anything.
+func (client *Client) MoveTag(ctx context.Context, fromTag, toTag string) (*ModifyResponse, error) {
+	from, to := client.normalizeTag(fromTag), client.normalizeTag(toTag)
+	if len(from) == 0 || len(to) == 0 {
+		return nil, ErrEmptyTag
+	}
+	if from == to {
+		// adding and then removing it would strip the tag from every item
+		return nil, fmt.Errorf("can't move tag %q to itself", fromTag)
+	}
+	itemIds, err := client.matchingIds(ctx, NewRetrieveRequest().OnlyState(StateAll).OnlyTag(from), nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(itemIds) == 0 {
+		return &ModifyResponse{Status: 1}, nil
+	}
+
+	req := new(ModifyRequest)
+	for _, id := range itemIds {
+		req.AddAction(Action{Kind: ActionTagsAdd, Params: map[string]string{"item_id": id, "tags": to}})
+		req.AddAction(Action{Kind: ActionTagsRemove, Params: map[string]string{"item_id": id, "tags": from}})
+	}
+	// split on an even boundary so that a failed batch can't leave an item
+	// with both tags
+	chunkSize := client.maxActions
+	if chunkSize > 1 {
+		chunkSize -= chunkSize % 2
+	}
+	return client.ModifyChunked(ctx, req, chunkSize)
+}
+
+// TagDomain adds tag to every item (in any state) from domain, e.g. to tag
+// everything from a news site "news". The tags_add actions are sent like
+// ModifyChunked. An empty tag fails with ErrEmptyTag and an empty domain
+// with an error, before anything is retrieved.
+func (client *Client) TagDomain(ctx context.Context, domain, tag string) (*ModifyResponse, error) {
+	if len(client.normalizeTag(tag)) == 0 {
+		return nil, ErrEmptyTag
+	}
+	if len(domain) == 0 {
+		// pocket would ignore the filter and match every item
+		return nil, errors.New("empty domain")
+	}
+	itemIds, err := client.matchingIds(ctx, NewRetrieveRequest().OnlyState(StateAll).OnlyDomain(domain), nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(itemIds) == 0 {
+		return &ModifyResponse{Status: 1}, nil
+	}
+
+	req := new(ModifyRequest)
+	for _, id := range itemIds {
+		req.AddAction(NewTagsAddAction(id, []string{tag}))
+	}
+	return client.ModifyChunked(ctx, req, 0)
+}
+
+// private methods
+
+// modifyChunked is ModifyChunked, calling onChunk (if not nil) with the
+// results so far after every batch.
+func (client *Client) modifyChunked(ctx context.Context,
+	req *ModifyRequest, chunkSize int, onChunk func(merged *ModifyResponse)) (*ModifyResponse, error) {
+	if req == nil {
+		return nil, ErrNilRequest
+	}
+	if chunkSize <= 0 {
+		chunkSize = client.maxActions
+	}
+	if chunkSize <= 0 {
+		// no limit, so the whole batch is a single chunk
+		chunkSize = len(req.actions)
+	}
+
+	ctx = client.withRetryBudget(ctx)
+	merged := &ModifyResponse{Status: 1}
+	for start := 0; start < len(req.actions); start += chunkSize {
+		if err := ctx.Err(); err != nil {
+			return merged, err
+		}
+		end := start + chunkSize
+		if end > len(req.actions) {
+			end = len(req.actions)
+		}
+		resp, err := client.modifyTyped(ctx, &ModifyRequest{actions: req.actions[start:end]})
+		if err != nil {
+			return merged, err
+		}
+		if resp.Status != 1 {
+			merged.Status = resp.Status
+		}
+		merged.ActionResults = append(merged.ActionResults, resp.ActionResults...)
+		if onChunk != nil {
+			onChunk(merged)
+		}
+	}
+	return merged, nil
+}
+
+func copyParams(params map[string]string) map[string]string {
+	if params == nil {
+		return nil
+	}
+	c := make(map[string]string, len(params))
+	for k, v := range params {
+		c[k] = v
+	}
+	return c
+}
+
+// modifyMatching applies kind to every item matching req for which keep
+// returns true (or to all of them if keep is nil).
+func (client *Client) modifyMatching(ctx context.Context,
+	kind ActionKind, req *RetrieveRequest, keep func(Item) bool) (*ModifyResponse, error) {
+	itemIds, err := client.matchingIds(ctx, req, keep)
+	if err != nil {
+		return nil, err
+	}
+	return client.modifyMany(ctx, kind, itemIds)
+}
+
+// matchingIds pages through the items matching req and returns the ids of
+// those keep accepts (all of them if keep is nil).
+func (client *Client) matchingIds(ctx context.Context, req *RetrieveRequest, keep func(Item) bool) ([]string, error) {
+	var itemIds []string
+	it := client.Iterate(ctx, req)
+	defer it.Close()
+	for it.Next() {
+		if item := it.Item(); keep == nil || keep(item) {
+			itemIds = append(itemIds, item.ItemID)
+		}
+	}
+	return itemIds, it.Err()
+}
+
+func (client *Client) modifyMany(ctx context.Context, kind ActionKind, itemIds []string) (*ModifyResponse, error) {
+	if len(itemIds) == 0 {
+		return &ModifyResponse{Status: 1}, nil
+	}
+
+	req := new(ModifyRequest)
+	for _, id := range itemIds {
+		req.AddAction(Action{Kind: kind, Params: map[string]string{"item_id": id}})
+	}
+	return client.ModifyChunked(ctx, req, 0)
+}
+
+func (client *Client) modifyTyped(ctx context.Context, req *ModifyRequest) (*ModifyResponse, error) {
+	respBytes, err := client.modify(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(ModifyResponse)
+	if err := json.Unmarshal(respBytes, resp); err != nil {
+		return nil, fmt.Errorf("Error parsing http response: %s", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,531 @@
+package pocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestModifyGetThreshold(t *testing.T) {
+	archive := func(n int) *ModifyRequest {
+		req := new(ModifyRequest)
+		for i := 0; i < n; i++ {
+			req.AddAction(Action{Kind: ActionArchive, Params: map[string]string{"item_id": strconv.Itoa(i)}})
+		}
+		return req
+	}
+
+	tests := []struct {
+		name      string
+		threshold int
+		actions   int
+		want      string
+	}{
+		{"small batch", defaultModifyGetThreshold, 1, "GET"},
+		{"large batch", defaultModifyGetThreshold, 60, "POST"},
+		{"under custom threshold", 500, 3, "GET"},
+		{"over custom threshold", 500, 10, "POST"},
+		{"always post", 0, 1, "POST"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newFakePocket(t)
+			client := p.client(WithModifyGetThreshold(tt.threshold))
+			if _, err := client.Modify(archive(tt.actions)); err != nil {
+				t.Fatal(err)
+			}
+			if len(p.methods) != 1 || p.methods[0] != tt.want {
+				t.Errorf("sent with %v, want %s", p.methods, tt.want)
+			}
+			if n := len(p.modifies[0]); n != tt.actions {
+				t.Errorf("sent %d actions, want %d", n, tt.actions)
+			}
+		})
+	}
+}
+
+func TestModifyMany(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(*Client, context.Context, []string) (*ModifyResponse, error)
+		kind string
+	}{
+		{"FavoriteMany", (*Client).FavoriteMany, "favorite"},
+		{"ArchiveMany", (*Client).ArchiveMany, "archive"},
+		{"DeleteMany", (*Client).DeleteMany, "delete"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newFakePocket(t)
+			resp, err := tt.fn(p.client(), context.Background(), []string{"1", "2", "3"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(p.modifies) != 1 {
+				t.Fatalf("made %d modify calls, want 1", len(p.modifies))
+			}
+			want := []string{tt.kind + " 1", tt.kind + " 2", tt.kind + " 3"}
+			if got := actionsOf(p.modifies[0]); !reflect.DeepEqual(got, want) {
+				t.Errorf("sent %v, want %v", got, want)
+			}
+			if len(resp.ActionResults) != 3 {
+				t.Errorf("got %d results, want 3", len(resp.ActionResults))
+			}
+		})
+	}
+}
+
+func TestReaddMatching(t *testing.T) {
+	p := newFakePocket(t, `{"item_id":"1","status":"1"}`, `{"item_id":"2","status":"1"}`)
+	if _, err := p.client().ReaddMatching(context.Background(), NewRetrieveRequest().OnlyTag("go")); err != nil {
+		t.Fatal(err)
+	}
+	if params := p.retrieves[0]; params["state"] != "archive" || params["tag"] != "go" {
+		t.Errorf("retrieved with %v, want archived items tagged go", params)
+	}
+	if got, want := actionsOf(p.modifies[0]), []string{"readd 1", "readd 2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+}
+
+func TestReaddMatchingKeepsRequest(t *testing.T) {
+	p := newFakePocket(t, `{"item_id":"1","status":"1"}`)
+	req := NewRetrieveRequest().OnlyState(StateUnread).OnlyTag("later")
+	if _, err := p.client().ReaddMatching(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.retrieves[0]["state"]; got != "archive" {
+		t.Errorf("retrieved state %q, want archive", got)
+	}
+	want := map[string]string{"state": "unread", "tag": "later"}
+	if !reflect.DeepEqual(req.params, want) {
+		t.Errorf("caller's request params %v, want %v", req.params, want)
+	}
+}
+
+func TestArchiveMatchingSkipsArchived(t *testing.T) {
+	p := newFakePocket(t, `{"item_id":"1","status":"0"}`, `{"item_id":"2","status":"1"}`,
+		`{"item_id":"3","status":"0"}`)
+	resp, err := p.client().ArchiveMatching(context.Background(), NewRetrieveRequest().OnlyState(StateAll))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := actionsOf(p.modifies[0]), []string{"archive 1", "archive 3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+	if len(resp.ActionResults) != 2 {
+		t.Errorf("got %d results, want 2", len(resp.ActionResults))
+	}
+}
+
+func TestModifyAtomic(t *testing.T) {
+	p := newFakePocket(t)
+	p.failAction = func(a map[string]string) bool {
+		return a["action"] == "favorite" && a["item_id"] == "2"
+	}
+	req := new(ModifyRequest)
+	req.AddAction(Action{Kind: ActionArchive, Params: map[string]string{"item_id": "1"}})
+	req.AddAction(Action{Kind: ActionFavorite, Params: map[string]string{"item_id": "2"}})
+	req.AddAction(Action{Kind: ActionFavorite, Params: map[string]string{"item_id": "3"}})
+
+	resp, err := p.client().ModifyAtomic(req)
+	var rbErr *RollbackError
+	if !errors.As(err, &rbErr) {
+		t.Fatalf("got %v, want a *RollbackError", err)
+	}
+	if resp == nil || resp.Succeeded(1) {
+		t.Errorf("got response %+v, want the original one", resp)
+	}
+	if !reflect.DeepEqual(rbErr.Failed, []int{1}) || len(rbErr.RolledBack) != 2 ||
+		len(rbErr.NotRolledBack) != 0 || rbErr.Err != nil {
+		t.Errorf("got %+v", rbErr)
+	}
+	if len(p.modifies) != 2 {
+		t.Fatalf("made %d modify calls, want 2", len(p.modifies))
+	}
+	if got, want := actionsOf(p.modifies[1]), []string{"unfavorite 3", "readd 1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rolled back with %v, want %v", got, want)
+	}
+}
+
+func TestTagNormalizationLeavesNoTags(t *testing.T) {
+	p := newFakePocket(t)
+	client := p.client(WithTagNormalization(false))
+
+	replace, err := NewTagsReplaceAction("1", []string{" "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := new(ModifyRequest)
+	req.AddAction(replace)
+	if _, err := client.Modify(req); err != ErrNoTags {
+		t.Errorf("got %v, want ErrNoTags", err)
+	}
+	if len(p.modifies) != 0 {
+		t.Errorf("sent %v, want nothing", p.modifies)
+	}
+
+	req = new(ModifyRequest)
+	req.AddAction(Action{Kind: ActionAdd, Params: map[string]string{"url": "https://example.com", "tags": " ,"}})
+	if _, err := client.Modify(req); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.modifies[0][0]["tags"]; ok {
+		t.Errorf("add action sent tags %q, want none", p.modifies[0][0]["tags"])
+	}
+}
+
+func TestModifyTooManyActions(t *testing.T) {
+	p := newFakePocket(t)
+	req := new(ModifyRequest)
+	for _, id := range []string{"1", "2", "3"} {
+		req.AddAction(Action{Kind: ActionArchive, Params: map[string]string{"item_id": id}})
+	}
+
+	_, err := p.client(WithMaxActions(2)).Modify(req)
+	var tooMany *TooManyActionsError
+	if !errors.As(err, &tooMany) || tooMany.Count != 3 || tooMany.Max != 2 {
+		t.Fatalf("got %v, want a *TooManyActionsError for 3 of 2", err)
+	}
+	if !strings.Contains(err.Error(), "ModifyChunked") {
+		t.Errorf("error %q doesn't suggest ModifyChunked", err)
+	}
+	if len(p.modifies) != 0 {
+		t.Error("sent the batch anyway")
+	}
+
+	if _, err := p.client(WithMaxActions(0)).Modify(req); err != nil {
+		t.Errorf("without a maximum: %v", err)
+	}
+}
+
+func TestEmptyTags(t *testing.T) {
+	if _, err := NewTagsReplaceAction("1", nil); err != ErrNoTags {
+		t.Errorf("replacing with no tags: got %v, want ErrNoTags", err)
+	}
+	a, err := NewTagsReplaceAction("1", []string{"go", "web"})
+	if err != nil || a.Params["tags"] != "go,web" {
+		t.Errorf("got %+v, %v", a, err)
+	}
+
+	req := new(ModifyRequest)
+	req.AddAction(NewTagsAddAction("1", nil))
+	req.AddAction(NewTagsClearAction("1"))
+	if len(req.actions) != 1 || req.actions[0].Kind != ActionTagsClear {
+		t.Errorf("got actions %+v, want the tags_add skipped", req.actions)
+	}
+}
+
+func TestFailOnActionError(t *testing.T) {
+	failSecond := func(a map[string]string) bool { return a["item_id"] == "2" }
+	req := new(ModifyRequest)
+	for _, id := range []string{"1", "2", "3"} {
+		req.AddAction(Action{Kind: ActionArchive, Params: map[string]string{"item_id": id}})
+	}
+
+	p := newFakePocket(t)
+	p.failAction = failSecond
+	resp, err := p.client(WithFailOnActionError()).ModifyTyped(req)
+	var failed *ActionsFailedError
+	if !errors.As(err, &failed) || !reflect.DeepEqual(failed.Failed, []int{1}) {
+		t.Errorf("got %v, want action 1 reported as failed", err)
+	}
+	if resp == nil || len(resp.ActionResults) != 3 {
+		t.Errorf("got response %+v, want it returned with the error", resp)
+	}
+
+	p = newFakePocket(t)
+	p.failAction = failSecond
+	resp, err = p.client().ModifyTyped(req)
+	if err != nil || resp.Succeeded(1) || !resp.Succeeded(2) {
+		t.Errorf("lenient: got %+v, %v", resp, err)
+	}
+}
+
+func TestNewAction(t *testing.T) {
+	params := map[string]string{"item_id": "1", "time": "100"}
+	a := NewAction("future_action", params)
+	params["item_id"] = "2"
+	b := NewAction("future_action", params)
+
+	if a.Kind != "future_action" || a.Params["item_id"] != "1" || b.Params["item_id"] != "2" {
+		t.Errorf("got %+v and %+v", a, b)
+	}
+	if a := NewAction(ActionArchive, nil); a.Params != nil {
+		t.Errorf("got params %v from nil", a.Params)
+	}
+}
+
+func TestAddActionCopiesParams(t *testing.T) {
+	shared := map[string]string{"item_id": "1"}
+	req := new(ModifyRequest)
+	req.AddAction(Action{Kind: ActionArchive, Params: shared})
+	shared["item_id"] = "2"
+	req.AddAction(Action{Kind: ActionFavorite, Params: shared})
+	shared["item_id"] = "3"
+
+	if got := req.actions[0].Params["item_id"] + req.actions[1].Params["item_id"]; got != "12" {
+		t.Errorf("got item ids %q, want 1 and 2", got)
+	}
+}
+
+func TestMoveTag(t *testing.T) {
+	p := newFakePocket(t, numberedItems(2)...)
+	if _, err := p.client().MoveTag(context.Background(), "go", "golang"); err != nil {
+		t.Fatal(err)
+	}
+	if params := p.retrieves[0]; params["tag"] != "go" || params["state"] != "all" {
+		t.Errorf("retrieved with %v, want items tagged go in every state", params)
+	}
+	want := []string{"tags_add 1 golang", "tags_remove 1 go", "tags_add 2 golang", "tags_remove 2 go"}
+	if len(p.modifies) != 1 || !reflect.DeepEqual(actionsOf(p.modifies[0]), want) {
+		t.Errorf("sent %v, want one batch of %v", p.modifies, want)
+	}
+
+	p = newFakePocket(t)
+	if resp, err := p.client().MoveTag(context.Background(), "go", "golang"); err != nil || resp.Status != 1 {
+		t.Errorf("no items: got %+v, %v", resp, err)
+	}
+	if len(p.modifies) != 0 {
+		t.Error("no items: sent an empty batch")
+	}
+}
+
+func TestMoveTagInvalid(t *testing.T) {
+	tests := []struct {
+		from, to string
+		opts     []Option
+		want     error
+	}{
+		{"go", "", nil, ErrEmptyTag},
+		{"", "golang", nil, ErrEmptyTag},
+		{"go", " ", []Option{WithTagNormalization(false)}, ErrEmptyTag},
+		{"go", "go", nil, nil},
+		{"Go", "go", []Option{WithTagNormalization(true)}, nil},
+	}
+	for _, tt := range tests {
+		p := newFakePocket(t, `{"item_id":"1"}`)
+		_, err := p.client(tt.opts...).MoveTag(context.Background(), tt.from, tt.to)
+		if err == nil || (tt.want != nil && err != tt.want) {
+			t.Errorf("MoveTag(%q, %q): got %v, want an error", tt.from, tt.to, err)
+		}
+		if len(p.retrieves)+len(p.modifies) > 0 {
+			t.Errorf("MoveTag(%q, %q) sent requests", tt.from, tt.to)
+		}
+	}
+}
+
+func TestMoveTagKeepsPairsTogether(t *testing.T) {
+	p := newFakePocket(t, numberedItems(3)...)
+	client := p.client(WithMaxActions(3), WithTagNormalization(true))
+	if _, err := client.MoveTag(context.Background(), " Go ", "GoLang"); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.retrieves[0]["tag"]; got != "go" {
+		t.Errorf("retrieved tag %q, want the normalized go", got)
+	}
+	var got [][]string
+	for _, actions := range p.modifies {
+		got = append(got, actionsOf(actions))
+	}
+	want := [][]string{
+		{"tags_add 1 golang", "tags_remove 1 go"},
+		{"tags_add 2 golang", "tags_remove 2 go"},
+		{"tags_add 3 golang", "tags_remove 3 go"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+}
+
+func TestAddBatchChunks(t *testing.T) {
+	p := newFakePocket(t)
+	p.failAction = func(a map[string]string) bool { return a["url"] == "https://example.com/150" }
+	reqs := make([]*AddRequest, 300)
+	for i := range reqs {
+		reqs[i] = new(AddRequest).SetUrl(fmt.Sprintf("https://example.com/%d", i+1))
+	}
+
+	var progress []string
+	result, err := p.client().AddBatchProgress(context.Background(), reqs, 100, func(succeeded, failed int) {
+		progress = append(progress, fmt.Sprintf("%d/%d", succeeded, failed))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.modifies) != 3 {
+		t.Fatalf("made %d modify calls, want 3", len(p.modifies))
+	}
+	for i, actions := range p.modifies {
+		if len(actions) != 100 {
+			t.Errorf("batch %d has %d actions, want 100", i, len(actions))
+		}
+	}
+	if len(result.Items) != 300 || result.Items[149] != nil || result.Items[150] == nil ||
+		result.Items[0].GivenURL != "https://example.com/1" {
+		t.Errorf("got %d items", len(result.Items))
+	}
+	if !reflect.DeepEqual(result.Failed, []string{"https://example.com/150"}) {
+		t.Errorf("got failed %v", result.Failed)
+	}
+	if want := []string{"100/0", "199/1", "299/1"}; !reflect.DeepEqual(progress, want) {
+		t.Errorf("reported progress %v, want %v", progress, want)
+	}
+}
+
+func TestModifyDependent(t *testing.T) {
+	p := newFakePocket(t)
+	req := new(ModifyRequest)
+	req.AddAction(Action{Kind: ActionAdd, Params: map[string]string{"url": "https://example.com/a"}})
+	req.AddAction(Action{Kind: ActionArchive, Params: map[string]string{"item_id": "7"}})
+	req.AddAction(Action{Kind: ActionFavorite, Params: map[string]string{"url": "https://example.com/a"}})
+
+	resp, err := p.client().ModifyDependent(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.modifies) != 2 {
+		t.Fatalf("made %d modify calls, want 2", len(p.modifies))
+	}
+	if got, want := actionsOf(p.modifies[0]), []string{"add ", "archive 7"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("first call sent %v, want %v", got, want)
+	}
+	if got, want := actionsOf(p.modifies[1]), []string{"favorite 1001"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("second call sent %v, want %v", got, want)
+	}
+	if len(resp.ActionResults) != 3 {
+		t.Errorf("got %d results, want 3", len(resp.ActionResults))
+	}
+
+	// without a dependency everything goes out at once
+	p = newFakePocket(t)
+	req = new(ModifyRequest)
+	req.AddAction(Action{Kind: ActionAdd, Params: map[string]string{"url": "https://example.com/a"}})
+	req.AddAction(Action{Kind: ActionFavorite, Params: map[string]string{"item_id": "7"}})
+	if _, err := p.client().ModifyDependent(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.modifies) != 1 {
+		t.Errorf("made %d modify calls, want 1", len(p.modifies))
+	}
+}
+
+func TestTagDomain(t *testing.T) {
+	p := newFakePocket(t, numberedItems(2)...)
+	if _, err := p.client().TagDomain(context.Background(), "example.com", "news"); err != nil {
+		t.Fatal(err)
+	}
+	if params := p.retrieves[0]; params["domain"] != "example.com" || params["state"] != "all" {
+		t.Errorf("retrieved with %v, want items from example.com in every state", params)
+	}
+	if got, want := actionsOf(p.modifies[0]), []string{"tags_add 1 news", "tags_add 2 news"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+}
+
+func TestTagDomainInvalid(t *testing.T) {
+	tests := []struct {
+		domain, tag string
+		want        error
+	}{
+		{"example.com", "", ErrEmptyTag},
+		{"", "news", nil},
+	}
+	for _, tt := range tests {
+		p := newFakePocket(t, `{"item_id":"1"}`)
+		_, err := p.client().TagDomain(context.Background(), tt.domain, tt.tag)
+		if err == nil || (tt.want != nil && err != tt.want) {
+			t.Errorf("TagDomain(%q, %q): got %v, want an error", tt.domain, tt.tag, err)
+		}
+		if len(p.retrieves)+len(p.modifies) > 0 {
+			t.Errorf("TagDomain(%q, %q) sent requests", tt.domain, tt.tag)
+		}
+	}
+}
+
+func TestAddBatchCancel(t *testing.T) {
+	p := newFakePocket(t)
+	reqs := make([]*AddRequest, 6)
+	for i := range reqs {
+		reqs[i] = new(AddRequest).SetUrl(fmt.Sprintf("https://example.com/%d", i+1))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result, err := p.client().AddBatchProgress(ctx, reqs, 2, func(succeeded, failed int) {
+		cancel()
+	})
+	if err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if len(p.modifies) != 1 {
+		t.Errorf("made %d modify calls, want 1", len(p.modifies))
+	}
+	if len(result.Items) != 2 || len(result.Failed) != 0 {
+		t.Errorf("got %d items and failed %v, want the first batch", len(result.Items), result.Failed)
+	}
+}
+
+func TestAddBatchProgressWithoutMaxActions(t *testing.T) {
+	urls := []string{"https://example.com/1", "https://example.com/2", "https://example.com/3"}
+	reqs := make([]*AddRequest, len(urls))
+	for i, u := range urls {
+		reqs[i] = new(AddRequest).SetUrl(u)
+	}
+
+	p := newFakePocket(t)
+	client := p.client(WithMaxActions(0))
+	var progress [][2]int
+	_, err := client.AddBatchProgress(context.Background(), reqs, 0, func(succeeded, failed int) {
+		progress = append(progress, [2]int{succeeded, failed})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.modifies) != 1 || len(p.modifies[0]) != 3 {
+		t.Errorf("sent %v, want one batch of 3", p.modifies)
+	}
+	if want := [][2]int{{3, 0}}; !reflect.DeepEqual(progress, want) {
+		t.Errorf("progress %v, want %v", progress, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p = newFakePocket(t)
+	if _, err := p.client(WithMaxActions(0)).AddBatch(ctx, reqs, 0); err != context.Canceled {
+		t.Errorf("cancelled: got %v, want context.Canceled", err)
+	}
+	if len(p.modifies) != 0 {
+		t.Errorf("cancelled: sent %v", p.modifies)
+	}
+}
+
+func TestClearFavorites(t *testing.T) {
+	p := newFakePocket(t, numberedItems(2)...)
+	client := p.client()
+
+	asked := -1
+	if resp, err := client.ClearFavorites(context.Background(), func(n int) bool {
+		asked = n
+		return false
+	}); err != nil || resp == nil || resp.Status != 1 || len(resp.ActionResults) != 0 {
+		t.Errorf("declined: got %+v, %v, want an empty response", resp, err)
+	}
+	if asked != 2 || len(p.modifies) != 0 {
+		t.Errorf("declined: asked about %d favorites and sent %v", asked, p.modifies)
+	}
+
+	if _, err := client.ClearFavorites(context.Background(), func(int) bool { return true }); err != nil {
+		t.Fatal(err)
+	}
+	if params := p.retrieves[0]; params["favorite"] != "1" || params["state"] != "all" {
+		t.Errorf("retrieved with %v, want favorites in every state", params)
+	}
+	if got, want := actionsOf(p.modifies[0]), []string{"unfavorite 1", "unfavorite 2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+}
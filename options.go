@@ -0,0 +1,262 @@
+package pocket
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Option configures optional Client behaviour. Options are passed to
+// NewClient or NewClientWithAccessToken.
+type Option func(*Client)
+
+// WithModifyGetThreshold sets the encoded url length from which Modify sends
+// its actions in a POST body instead of a GET query string. A threshold of 0
+// always uses POST.
+func WithModifyGetThreshold(n int) Option {
+	return func(client *Client) {
+		client.modifyGetThreshold = n
+	}
+}
+
+// WithRedirectURI stores the redirect uri used by the auth helpers, so that
+// NewRequestToken and GetAuthorizationUrl can be called with an empty one.
+func WithRedirectURI(uri string) Option {
+	return func(client *Client) {
+		client.redirectUri = uri
+	}
+}
+
+// WithMaxIdleConns sets how many idle connections to pocket the client keeps
+// around for reuse. Raise it for tools making many requests in a row. Like
+// WithIdleConnTimeout, it has no effect on a client given a RoundTripper
+// other than an *http.Transport, such as a ReplayClient.
+func WithMaxIdleConns(n int) Option {
+	return func(client *Client) {
+		if t := client.transport(); t != nil {
+			t.MaxIdleConns = n
+			t.MaxIdleConnsPerHost = n
+		}
+	}
+}
+
+// WithIdleConnTimeout sets how long an idle connection is kept before it is
+// closed.
+func WithIdleConnTimeout(d time.Duration) Option {
+	return func(client *Client) {
+		if t := client.transport(); t != nil {
+			t.IdleConnTimeout = d
+		}
+	}
+}
+
+// WithHeader adds a header to every request the client sends, e.g. for a
+// proxy in front of pocket. It can't override headers the library sets
+// itself such as Content-Type, except for the default Accept header.
+func WithHeader(key, value string) Option {
+	return func(client *Client) {
+		if client.headers == nil {
+			client.headers = make(http.Header)
+		}
+		client.headers.Add(key, value)
+	}
+}
+
+// WithMaxRetrieveItems caps how many items RetrieveAllItems collects, as a
+// guard against pulling a huge list into memory by accident. The default of
+// 0 means no cap.
+func WithMaxRetrieveItems(n int) Option {
+	return func(client *Client) {
+		client.maxRetrieveItems = n
+	}
+}
+
+// WithProgress calls fn as response bodies are read, with the number of
+// bytes read so far and the total from Content-Length (-1 when the server
+// didn't send one). Useful for progress bars on large retrieves.
+func WithProgress(fn func(read, total int64)) Option {
+	return func(client *Client) {
+		client.progress = fn
+	}
+}
+
+// WithTagNormalization trims tags and drops empty and duplicate ones before
+// they are sent with Add or a tagging action. With lowercase set, tags are
+// also lowercased so that "Go" and "go" end up as one tag.
+func WithTagNormalization(lowercase bool) Option {
+	return func(client *Client) {
+		client.normalizeTags = true
+		client.lowercaseTags = lowercase
+	}
+}
+
+// WithRequestIdHeader sets header (e.g. "X-Request-Id") on every request
+// to the value stored under key in the request's context, so calls can be
+// traced through proxies. The value must be a string or a fmt.Stringer;
+// requests whose context has no value are sent without the header.
+func WithRequestIdHeader(header string, key interface{}) Option {
+	return func(client *Client) {
+		client.requestIdHeader = header
+		client.requestIdKey = key
+	}
+}
+
+// WithRetrieveConcurrency makes RetrieveAllItems fetch up to n pages at a
+// time instead of one after the other.
+func WithRetrieveConcurrency(n int) Option {
+	return func(client *Client) {
+		client.retrieveWorkers = n
+	}
+}
+
+// WithMaxActions sets how many actions a single modify call may carry; larger
+// batches fail with a *TooManyActionsError. It defaults to 100. A value of 0
+// removes the check.
+func WithMaxActions(n int) Option {
+	return func(client *Client) {
+		client.maxActions = n
+	}
+}
+
+// WithHTTPClient makes the client send its requests through a copy of c,
+// e.g. one returned by ReplayClient.
+func WithHTTPClient(c *http.Client) Option {
+	return func(client *Client) {
+		cc := *c
+		client.c = &cc
+	}
+}
+
+// WithRetry retries requests that fail with a network error, a 5xx response
+// or rate limiting, backing off between attempts as described by opts.
+func WithRetry(opts RetryOptions) Option {
+	return func(client *Client) {
+		client.retry = opts
+	}
+}
+
+// WithRetryPolicy replaces the default decision of which failed requests
+// WithRetry retries. policy is called for every failed attempt with pocket's
+// response, whose body has already been read (nil if pocket didn't answer),
+// and the error; it returns whether to retry. MaxRetries and the backoff of
+// WithRetry still apply.
+func WithRetryPolicy(policy func(resp *http.Response, err error) bool) Option {
+	return func(client *Client) {
+		client.retryPolicy = policy
+	}
+}
+
+// WithItemCache makes GetItem and GetItems keep the items they fetch in
+// memory for ttl and answer from there while the items are fresh. Modifying
+// or re-adding an item through the client (Modify, Archive, FavoriteMany and
+// the like, or Add) drops it from the cache; actions on tags across the list
+// drop everything.
+func WithItemCache(ttl time.Duration) Option {
+	return func(client *Client) {
+		client.itemCache = newItemCache(ttl)
+	}
+}
+
+// WithMetrics calls sink after every call to pocket's item endpoints with the
+// operation ("retrieve", "add" or "modify"), how long it took including
+// retries, and the error it failed with, if any. It's meant for feeding
+// latency metrics to an external system and is called synchronously, so it
+// should be quick.
+func WithMetrics(sink func(op string, dur time.Duration, err error)) Option {
+	return func(client *Client) {
+		client.metrics = sink
+	}
+}
+
+// WithClock replaces the clock used to wait between retries and by
+// ModifiedWithin.
+func WithClock(clock Clock) Option {
+	return func(client *Client) {
+		client.clock = clock
+	}
+}
+
+// WithCaptureHeaders keeps the named headers of every successful response
+// (e.g. diagnostic headers pocket echoes back) so that they can be read with
+// ResponseHeaders.
+func WithCaptureHeaders(names ...string) Option {
+	return func(client *Client) {
+		client.captureHeaders = append(client.captureHeaders, names...)
+	}
+}
+
+// WithReauth sets a hook that is called when a request fails with
+// ErrInvalidToken, e.g. because the user revoked access mid-sync. The hook
+// obtains and returns a new token, which the client stores as its
+// AccessToken; the failed request is then retried once with it. Requests
+// failing concurrently with the same token share a single call of the hook.
+func WithReauth(fn func(ctx context.Context) (string, error)) Option {
+	return func(client *Client) {
+		client.reauth = fn
+	}
+}
+
+// WithFailOnActionError makes ModifyTyped return an error when any action of
+// the batch fails, instead of only reporting it in the response.
+func WithFailOnActionError() Option {
+	return func(client *Client) {
+		client.failOnActionError = true
+	}
+}
+
+// WithURLResolver sets a function that Add, AddTyped and QuickAdd pass every
+// url through before saving it, e.g. to expand t.co or bit.ly links so that
+// pocket gets the canonical url. If it fails, nothing is saved.
+func WithURLResolver(fn func(ctx context.Context, url string) (string, error)) Option {
+	return func(client *Client) {
+		client.urlResolver = fn
+	}
+}
+
+// WithFormEncoding makes Add and Retrieve (and the calls built on them) send
+// form encoded bodies instead of JSON, for proxies that only pass those
+// through.
+func WithFormEncoding() Option {
+	return func(client *Client) {
+		client.formEncoding = true
+	}
+}
+
+// WithRecorder writes every request and response to a file in dir, with the
+// consumer key and tokens redacted. The recordings can be served again with
+// ReplayClient.
+func WithRecorder(dir string) Option {
+	return func(client *Client) {
+		client.recorderDir = dir
+	}
+}
+
+func (client *Client) apply(opts []Option) {
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	// wrap the transport last so that the other options tune the real one
+	if len(client.recorderDir) > 0 {
+		client.c.Transport = newRecorder(client.c.Transport, client.recorderDir)
+	}
+}
+
+// transport returns the client's own http.Transport, installing a copy of
+// the default one (which keeps HTTP/2 enabled) the first time it's tuned. It
+// returns nil if the client uses some other RoundTripper, which is left as is.
+func (client *Client) transport() *http.Transport {
+	switch t := client.c.Transport.(type) {
+	case nil:
+	case *http.Transport:
+		// don't tune the transport shared by every http.Client
+		if t != http.DefaultTransport {
+			return t
+		}
+	default:
+		return nil
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	client.c.Transport = t
+	return t
+}
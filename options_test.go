@@ -0,0 +1,118 @@
+package pocket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTransportOptions(t *testing.T) {
+	client := NewClient("consumer-key", WithMaxIdleConns(5), WithIdleConnTimeout(time.Minute))
+	tr, ok := client.c.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("got transport %T, want *http.Transport", client.c.Transport)
+	}
+	if tr.MaxIdleConns != 5 || tr.MaxIdleConnsPerHost != 5 || tr.IdleConnTimeout != time.Minute {
+		t.Errorf("got MaxIdleConns %d, MaxIdleConnsPerHost %d, IdleConnTimeout %s",
+			tr.MaxIdleConns, tr.MaxIdleConnsPerHost, tr.IdleConnTimeout)
+	}
+	if !tr.ForceAttemptHTTP2 {
+		t.Error("HTTP/2 was disabled")
+	}
+	if tr == http.DefaultTransport {
+		t.Error("tuned the default transport")
+	}
+}
+
+func TestTransportOptionsKeepCustomTransport(t *testing.T) {
+	hc, err := ReplayClient(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient("consumer-key", WithHTTPClient(hc), WithMaxIdleConns(5), WithIdleConnTimeout(time.Minute))
+	if client.c.Transport != hc.Transport {
+		t.Errorf("replaced the replaying transport with %T", client.c.Transport)
+	}
+
+	client = NewClient("consumer-key", WithHTTPClient(&http.Client{Transport: http.DefaultTransport}),
+		WithMaxIdleConns(5))
+	if client.c.Transport == http.DefaultTransport || http.DefaultTransport.(*http.Transport).MaxIdleConns == 5 {
+		t.Error("tuned the default transport")
+	}
+}
+
+func TestWithHeader(t *testing.T) {
+	var header http.Header
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		fmt.Fprint(w, `{"status":1,"list":[]}`)
+	}, WithHeader("X-Proxy-Auth", "secret"), WithHeader("Content-Type", "text/plain"))
+
+	if _, err := client.Retrieve(NewRetrieveRequest()); err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get("X-Proxy-Auth"); got != "secret" {
+		t.Errorf("got X-Proxy-Auth %q, want secret", got)
+	}
+	if got := header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("got Content-Type %q, want application/json", got)
+	}
+}
+
+type requestIdKey struct{}
+
+func TestRequestIdHeader(t *testing.T) {
+	var ids []string
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get("X-Request-Id"))
+		fmt.Fprint(w, `{"status":1,"action_results":[true]}`)
+	}, WithRequestIdHeader("X-Request-Id", requestIdKey{}))
+
+	ctx := context.WithValue(context.Background(), requestIdKey{}, "req-42")
+	if _, err := client.ArchiveMany(ctx, []string{"1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ArchiveMany(context.Background(), []string{"1"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"req-42", ""}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("sent request ids %q, want %q", ids, want)
+	}
+}
+
+func TestAcceptHeader(t *testing.T) {
+	var accepts []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		accepts = append(accepts, r.Header.Get("Accept"))
+		switch r.URL.Path {
+		case "/v3/get":
+			fmt.Fprint(w, `{"status":1,"list":[]}`)
+		case "/v3/add":
+			fmt.Fprint(w, `{"status":1,"item":{"item_id":"1"}}`)
+		case "/v3/send":
+			fmt.Fprint(w, `{"status":1,"action_results":[true]}`)
+		default:
+			fmt.Fprint(w, "code=request-token")
+		}
+	}
+
+	client := newTestClient(handler)
+	client.Retrieve(NewRetrieveRequest())
+	client.Add(new(AddRequest).SetUrl("https://example.com"))
+	client.ArchiveMany(context.Background(), []string{"1"})
+	client.NewRequestToken("https://app.example.com")
+	want := []string{"application/json", "application/json", "application/json", ""}
+	if !reflect.DeepEqual(accepts, want) {
+		t.Errorf("sent Accept %q, want %q", accepts, want)
+	}
+
+	accepts = nil
+	client = newTestClient(handler, WithHeader("Accept", "application/vnd.example+json"))
+	client.Retrieve(NewRetrieveRequest())
+	if want := []string{"application/vnd.example+json"}; !reflect.DeepEqual(accepts, want) {
+		t.Errorf("sent Accept %q, want the overridden one", accepts)
+	}
+}
@@ -2,13 +2,18 @@ package pocket
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -21,19 +26,64 @@ const (
 	retrieveUrl string = "https://getpocket.com/v3/get"
 	addUrl      string = "https://getpocket.com/v3/add"
 	modifyUrl   string = "https://getpocket.com/v3/send"
+
+	// modify requests whose encoded GET url would be at least this long are
+	// sent as a POST instead
+	defaultModifyGetThreshold int = 2000
+
+	// pocket doesn't document a limit on the actions of one modify call, so
+	// batches are capped at a size known to go through
+	defaultMaxActions int = 100
 )
 
 type Client struct {
 	ConsumerToken string
-	AccessToken   string
-	Username      string
-	c             *http.Client
+	// AccessToken must not be changed while requests are in flight; a new
+	// token obtained by a WithReauth hook is stored by the client itself.
+	AccessToken string
+	Username    string
+	// Permissions lists what the access token was granted, if pocket said so
+	// when authorizing. It's nil when unknown.
+	Permissions []string
+	c           *http.Client
+
+	redirectUri        string
+	modifyGetThreshold int
+	headers            http.Header
+	recorderDir        string
+	maxRetrieveItems   int
+	progress           func(read, total int64)
+	normalizeTags      bool
+	lowercaseTags      bool
+	requestIdHeader    string
+	requestIdKey       interface{}
+	retrieveWorkers    int
+	maxActions         int
+	retry              RetryOptions
+	retryPolicy        func(resp *http.Response, err error) bool
+	clock              Clock
+	randMu             sync.Mutex
+	captureHeaders     []string
+	headersMu          sync.Mutex
+	lastHeaders        http.Header
+	reauth             func(ctx context.Context) (string, error)
+	reauthMu           sync.Mutex
+	tokenMu            sync.RWMutex
+	failOnActionError  bool
+	urlResolver        func(ctx context.Context, url string) (string, error)
+	formEncoding       bool
+	quota              QuotaTracker
+	itemCache          *itemCache
+	metrics            func(op string, dur time.Duration, err error)
 }
 
 type Error struct {
 	StatusCode int
 	ErrorCode  int
 	ErrorMsg   string
+
+	// kind is the sentinel error (ErrInvalidToken etc.) the error matches
+	kind error
 }
 
 type SortKind int
@@ -61,11 +111,21 @@ const (
 	StateAll     ItemState = iota
 )
 
+type SaveOptions struct {
+	// SkipIfExists makes Add look the url up first and return the existing
+	// item instead of saving it again. This costs an extra retrieve call.
+	SkipIfExists bool
+	// AddMissingScheme makes Validate turn a url without a scheme, such as
+	// "www.example.com", into an https one instead of rejecting it.
+	AddMissingScheme bool
+}
+
 type AddRequest struct {
 	url     string
 	title   string
 	tags    []string
 	tweetId string
+	options SaveOptions
 }
 
 func (req *AddRequest) SetUrl(url string) *AddRequest {
@@ -88,8 +148,48 @@ func (req *AddRequest) SetTweetId(id string) *AddRequest {
 	return req
 }
 
+func (req *AddRequest) SetOptions(opts SaveOptions) *AddRequest {
+	req.options = opts
+	return req
+}
+
+// Validate checks the url before it's sent, which Add does too. A
+// protocol-relative url ("//example.com") is given https. A url without a
+// scheme or host is an error, unless the AddMissingScheme option is set, in
+// which case it's given https too.
+func (req *AddRequest) Validate() error {
+	if len(req.url) == 0 {
+		return errors.New("add request has no url")
+	}
+	if strings.HasPrefix(req.url, "//") {
+		req.url = "https:" + req.url
+	}
+	u, err := url.Parse(req.url)
+	if err != nil {
+		return fmt.Errorf("invalid url %q: %s", req.url, err)
+	}
+	if len(u.Scheme) == 0 || len(u.Host) == 0 {
+		// this includes "example.com:8080", which parses with example.com
+		// as the scheme
+		if !req.options.AddMissingScheme {
+			return fmt.Errorf("url %q has no scheme or host", req.url)
+		}
+		withScheme := "https://" + req.url
+		if u, err = url.Parse(withScheme); err != nil || len(u.Host) == 0 {
+			return fmt.Errorf("invalid url %q", req.url)
+		}
+		req.url = withScheme
+	}
+	return nil
+}
+
 type RetrieveRequest struct {
-	params map[string]string
+	params        map[string]string
+	pageSize      int
+	limit         int
+	startOffset   int
+	onOffset      func(offset int)
+	onlyAnnotated bool
 }
 
 func NewRetrieveRequest() *RetrieveRequest {
@@ -98,6 +198,20 @@ func NewRetrieveRequest() *RetrieveRequest {
 	return req
 }
 
+// UnreadArticles returns a request for the user's unread articles, newest
+// first.
+func UnreadArticles() *RetrieveRequest {
+	return NewRetrieveRequest().OnlyState(StateUnread).OnlyContentType(TypeArticle).Sort(SortNewest)
+}
+
+// FromDomain returns a request for the items from domain in the given state.
+func FromDomain(domain string, state ItemState) *RetrieveRequest {
+	return NewRetrieveRequest().OnlyDomain(domain).OnlyState(state)
+}
+
+// Sort orders the results. Pocket only documents newest, oldest, title and
+// site orderings; there is no relevance sort, even when searching. Sort and
+// Search are independent params and can be combined freely.
 func (req *RetrieveRequest) Sort(kind SortKind) *RetrieveRequest {
 	switch kind {
 	case SortNewest:
@@ -154,6 +268,19 @@ func (req *RetrieveRequest) OnlyUnFavorited() *RetrieveRequest {
 	return req
 }
 
+// OnlyWithAnnotations keeps only items the user highlighted. Pocket has no
+// param for this, so it asks for complete detail and the items are filtered
+// client side; it only has an effect on the typed paths (RetrieveTyped, the
+// iterator and the helpers built on them), not on Retrieve.
+func (req *RetrieveRequest) OnlyWithAnnotations() *RetrieveRequest {
+	req.onlyAnnotated = true
+	return req.CompleteItemInfo()
+}
+
+// OnlyState filters by state. StateAll covers unread and archived items;
+// deleted items are never part of a full retrieve. They only show up, with
+// StatusDeleted, in a retrieve with Since, so that syncs learn about the
+// deletion; there is no separate param for them.
 func (req *RetrieveRequest) OnlyState(state ItemState) *RetrieveRequest {
 	switch state {
 	case StateUnread:
@@ -166,16 +293,60 @@ func (req *RetrieveRequest) OnlyState(state ItemState) *RetrieveRequest {
 	return req
 }
 
+// Count limits the number of items returned. Count(0) (or less) removes the
+// limit again, so that every matching item is returned, rather than sending
+// a count of 0.
 func (req *RetrieveRequest) Count(count int) *RetrieveRequest {
-	req.params["count"] = string(count)
+	if count <= 0 {
+		delete(req.params, "count")
+		return req
+	}
+	req.params["count"] = strconv.Itoa(count)
 	return req
 }
 
+// Offset skips the first off items. Pocket ignores it unless Count is set
+// too, so Validate (and with it Retrieve) rejects an offset without a count
+// rather than silently returning the list from the start.
 func (req *RetrieveRequest) Offset(off int) *RetrieveRequest {
-	req.params["offset"] = string(off)
+	req.params["offset"] = strconv.Itoa(off)
+	return req
+}
+
+// Limit makes an ItemIterator (and so RetrieveAllItems) stop after n items
+// even if more match, without fetching further pages. 0 means no limit. It
+// has no effect on Retrieve; use Count there.
+func (req *RetrieveRequest) Limit(n int) *RetrieveRequest {
+	req.limit = n
+	return req
+}
+
+// ResumeAt makes an ItemIterator (and so RetrieveAllItems) start at offset
+// instead of at the first item, e.g. with an offset reported to OnOffset
+// before a crash.
+func (req *RetrieveRequest) ResumeAt(offset int) *RetrieveRequest {
+	req.startOffset = offset
+	return req
+}
+
+// OnOffset sets a function an ItemIterator (and so RetrieveAllItems) calls
+// after each page with the offset of the next one, once every item before
+// that offset was handed out. Saving it lets an interrupted export continue
+// with ResumeAt.
+func (req *RetrieveRequest) OnOffset(fn func(offset int)) *RetrieveRequest {
+	req.onOffset = fn
+	return req
+}
+
+// PageSize sets how many items an ItemIterator fetches per request. It has
+// no effect on Retrieve, which only honors Count.
+func (req *RetrieveRequest) PageSize(n int) *RetrieveRequest {
+	req.pageSize = n
 	return req
 }
 
+// Since only returns items changed after timestamp (a unix time, e.g. the
+// Since of a previous response), including items deleted since then.
 func (req *RetrieveRequest) Since(timestamp string) *RetrieveRequest {
 	req.params["since"] = timestamp
 	return req
@@ -186,11 +357,73 @@ func (req *RetrieveRequest) OnlyDomain(domain string) *RetrieveRequest {
 	return req
 }
 
+// Search only returns items whose title or url contain key. Results keep the
+// order chosen with Sort (newest first by default).
 func (req *RetrieveRequest) Search(key string) *RetrieveRequest {
 	req.params["search"] = key
 	return req
 }
 
+// Reset clears everything set on the request so it can be reused, keeping
+// the allocated params map.
+func (req *RetrieveRequest) Reset() *RetrieveRequest {
+	for k := range req.params {
+		delete(req.params, k)
+	}
+	req.pageSize = 0
+	req.limit = 0
+	req.startOffset = 0
+	req.onOffset = nil
+	req.onlyAnnotated = false
+	return req
+}
+
+// Validate checks the request for settings pocket would reject or silently
+// ignore and returns a *ValidationError listing all of them, or nil. Retrieve
+// and the iterators call it before sending anything.
+func (req *RetrieveRequest) Validate() error {
+	var problems []string
+	for _, key := range []string{"offset", "since"} {
+		if value, ok := req.params[key]; ok {
+			if n, err := strconv.ParseInt(value, 10, 64); err != nil || n < 0 {
+				problems = append(problems, fmt.Sprintf("%s %q is not a non-negative number", key, value))
+			}
+		}
+	}
+	if _, ok := req.params["count"]; !ok && atoi(req.params["offset"]) > 0 {
+		problems = append(problems, "offset is ignored without a count")
+	}
+	for _, key := range []string{"tag", "domain", "search"} {
+		if value, ok := req.params[key]; ok && len(value) == 0 {
+			problems = append(problems, fmt.Sprintf("%s is empty", key))
+		}
+	}
+	if req.pageSize < 0 {
+		problems = append(problems, fmt.Sprintf("page size %d is negative", req.pageSize))
+	}
+	if req.limit < 0 {
+		problems = append(problems, fmt.Sprintf("limit %d is negative", req.limit))
+	}
+	if req.startOffset < 0 {
+		problems = append(problems, fmt.Sprintf("resume offset %d is negative", req.startOffset))
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Clone returns a copy of the request that can be changed without affecting
+// the original, e.g. to build several variants of a base query.
+func (req *RetrieveRequest) Clone() *RetrieveRequest {
+	c := *req
+	c.params = make(map[string]string, len(req.params))
+	for k, v := range req.params {
+		c.params[k] = v
+	}
+	return &c
+}
+
 type ActionKind string
 
 const (
@@ -215,11 +448,23 @@ type Action struct {
 	Params map[string]string
 }
 
+// ModifyRequest is a batch of actions. Pocket applies them in order, but an
+// action can't refer to an item added earlier in the same batch, since its
+// item_id isn't known yet; ModifyDependent splits such batches.
 type ModifyRequest struct {
 	actions []Action
 }
 
+// AddAction appends a to the batch. A tags_add action without tags is a
+// no-op and is skipped, so it doesn't take up a slot in the results.
+//
+// a.Params is copied, so actions built from one shared map (or a map changed
+// after the call) don't affect each other.
 func (req *ModifyRequest) AddAction(a Action) {
+	if a.Kind == ActionTagsAdd && len(a.Params["tags"]) == 0 {
+		return
+	}
+	a.Params = copyParams(a.Params)
 	req.actions = append(req.actions, a)
 }
 
@@ -227,25 +472,39 @@ func (e *Error) Error() string {
 	return fmt.Sprintf("%d: %s", e.ErrorCode, e.ErrorMsg)
 }
 
-func NewClient(consumerToken string) *Client {
+// Is lets errors.Is match an *Error against ErrInvalidToken, ErrForbidden and
+// ErrRateLimited.
+func (e *Error) Is(target error) bool {
+	return e.kind != nil && e.kind == target
+}
+
+func NewClient(consumerToken string, opts ...Option) *Client {
 	c := &http.Client{}
-	client := &Client{ConsumerToken: consumerToken, c: c}
+	client := &Client{ConsumerToken: consumerToken, c: c, modifyGetThreshold: defaultModifyGetThreshold,
+		maxActions: defaultMaxActions, clock: realClock{}}
+	client.apply(opts)
 	return client
 }
 
-func NewClientWithAccessToken(consumerToken string, accessToken string, username string) *Client {
+func NewClientWithAccessToken(consumerToken string, accessToken string, username string, opts ...Option) *Client {
 	c := &http.Client{}
-	client := &Client{ConsumerToken: consumerToken, c: c, AccessToken: accessToken, Username: username}
+	client := &Client{ConsumerToken: consumerToken, c: c, AccessToken: accessToken, Username: username,
+		modifyGetThreshold: defaultModifyGetThreshold, maxActions: defaultMaxActions, clock: realClock{}}
+	client.apply(opts)
 	return client
 }
 
+// NewRequestToken fetches a request token for the auth flow. An empty
+// redirectUri falls back to the one the client was configured with; a
+// non-empty one is remembered for the later GetAuthorizationUrl call.
 func (client *Client) NewRequestToken(redirectUri string) (string, error) {
 	var requestToken string
 
+	redirectUri = client.rememberRedirectUri(redirectUri)
 	v := url.Values{}
 	v.Set("consumer_key", client.ConsumerToken)
 	v.Set("redirect_uri", redirectUri)
-	respStr, err := client.performPost(fetchRequestTokenUrl, v)
+	respStr, err := client.performPost(context.Background(), fetchRequestTokenUrl, v)
 	if err != nil {
 		return requestToken, err
 	}
@@ -258,19 +517,38 @@ func (client *Client) NewRequestToken(redirectUri string) (string, error) {
 	return requestToken, nil
 }
 
+// GetAuthorizationUrl returns the url to send the user to for authorizing
+// requestToken. An empty redirectUri falls back to the client's stored one.
+// redirectUri is query escaped as a whole, so custom scheme deep links such
+// as "myapp://auth?state=1" come back to the app unchanged.
 func (client *Client) GetAuthorizationUrl(requestToken string, redirectUri string) string {
+	redirectUri = client.rememberRedirectUri(redirectUri)
+
 	v := url.Values{}
 	v.Set("request_token", requestToken)
 	v.Set("redirect_uri", redirectUri)
 	return fmt.Sprintf(authorizationUrl, v.Encode())
 }
 
+// GetAuthorizationURLChecked is GetAuthorizationUrl, returning an error
+// instead of a broken url when the request token is empty or there's no
+// redirect uri (neither passed nor set with WithRedirectURI).
+func (client *Client) GetAuthorizationURLChecked(requestToken string, redirectUri string) (string, error) {
+	if len(requestToken) == 0 {
+		return "", errors.New("empty request token")
+	}
+	if len(redirectUri) == 0 && len(client.redirectUri) == 0 {
+		return "", errors.New("no redirect uri")
+	}
+	return client.GetAuthorizationUrl(requestToken, redirectUri), nil
+}
+
 func (client *Client) FetchAccessToken(requestToken string) error {
 	v := url.Values{}
 	v.Set("consumer_key", client.ConsumerToken)
 	v.Set("code", requestToken)
 
-	respStr, err := client.performPost(fetchAccessTokenUrl, v)
+	respStr, err := client.performPost(context.Background(), fetchAccessTokenUrl, v)
 	if err != nil {
 		return err
 	}
@@ -279,48 +557,171 @@ func (client *Client) FetchAccessToken(requestToken string) error {
 	if err != nil {
 		return fmt.Errorf("Error parsing http response: %s", err)
 	}
-	client.AccessToken = respValues.Get("access_token")
+	client.setAccessToken(respValues.Get("access_token"))
 	client.Username = respValues.Get("username")
+	client.Permissions = nil
+	for _, p := range respValues["permissions"] {
+		for _, s := range strings.Split(p, ",") {
+			if s = strings.TrimSpace(s); len(s) > 0 {
+				client.Permissions = append(client.Permissions, s)
+			}
+		}
+	}
 	return nil
 }
 
+// CanModify reports whether the access token may be used to modify the list.
+// Pocket grants permissions per consumer key and doesn't normally include
+// them when authorizing, so when they're unknown this assumes it can.
+func (client *Client) CanModify() bool {
+	if client.Permissions == nil {
+		return true
+	}
+	for _, p := range client.Permissions {
+		if p == "modify" {
+			return true
+		}
+	}
+	return false
+}
+
 func (client *Client) Retrieve(req *RetrieveRequest) (map[string]interface{}, error) {
-	if err := client.verifyAccessToken(); err != nil {
+	respBytes, err := client.retrieve(context.Background(), req)
+	if err != nil {
 		return nil, err
 	}
-
-	req.params["consumer_key"] = client.ConsumerToken
-	req.params["access_token"] = client.AccessToken
-	return client.performPostJson(retrieveUrl, req.params)
+	return decodeJsonMap(respBytes)
 }
 
 func (client *Client) Add(req *AddRequest) (map[string]interface{}, error) {
-	if err := client.verifyAccessToken(); err != nil {
+	respBytes, err := client.add(context.Background(), req)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJsonMap(respBytes)
+}
+
+func (client *Client) Modify(req *ModifyRequest) (map[string]interface{}, error) {
+	respBytes, err := client.modify(context.Background(), req)
+	if err != nil {
 		return nil, err
 	}
+	return decodeJsonMap(respBytes)
+}
+
+// ResponseHeaders returns the headers chosen with WithCaptureHeaders as sent
+// with the most recent successful response, or nil if there hasn't been one.
+// With concurrent calls it's unspecified which response that is.
+func (client *Client) ResponseHeaders() http.Header {
+	client.headersMu.Lock()
+	defer client.headersMu.Unlock()
+	return client.lastHeaders.Clone()
+}
+
+// private methods
+
+func (client *Client) retrieve(ctx context.Context, req *RetrieveRequest) ([]byte, error) {
+	if req == nil {
+		return nil, ErrNilRequest
+	}
+	if err := client.verifyCredentials(); err != nil {
+		return nil, err
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	// the request isn't changed, so it can be shared between goroutines
+	params := make(map[string]string, len(req.params)+2)
+	for k, v := range req.params {
+		params[k] = v
+	}
+	params["consumer_key"] = client.ConsumerToken
+	params["access_token"] = client.accessToken()
+	return client.postJson(ctx, retrieveUrl, params)
+}
+
+func (client *Client) add(ctx context.Context, req *AddRequest) ([]byte, error) {
+	if req == nil {
+		return nil, ErrNilRequest
+	}
+	if err := client.verifyCredentials(); err != nil {
+		return nil, err
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	itemUrl := req.url
+	if client.urlResolver != nil {
+		resolved, err := client.urlResolver(ctx, itemUrl)
+		if err != nil {
+			return nil, fmt.Errorf("Error resolving url %s: %s", itemUrl, err)
+		}
+		itemUrl = resolved
+	}
+
+	if req.options.SkipIfExists {
+		item, err := client.findSavedItem(ctx, itemUrl)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
+			// answer in the shape of an add response so callers can't tell
+			return json.Marshal(map[string]interface{}{"item": item, "status": 1})
+		}
+	}
 
 	params := make(map[string]string)
 	params["consumer_key"] = client.ConsumerToken
-	params["access_token"] = client.AccessToken
-	params["url"] = req.url
+	params["access_token"] = client.accessToken()
+	params["url"] = itemUrl
 
 	if len(req.title) > 0 {
 		params["title"] = req.title
 	}
-	if len(req.tags) > 0 {
-		params["tags"] = strings.Join(req.tags, ",")
+	if tags := client.tagList(req.tags); len(tags) > 0 {
+		params["tags"] = strings.Join(tags, ",")
 	}
 	if len(req.tweetId) > 0 {
 		params["tweet_id"] = req.tweetId
 	}
 
-	return client.performPostJson(addUrl, params)
+	respBytes, err := client.postJson(ctx, addUrl, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// pocket can answer 200 and still report a failed save in the body
+	var r struct {
+		Status int             `json:"status"`
+		Item   json.RawMessage `json:"item"`
+	}
+	if err := json.Unmarshal(respBytes, &r); err != nil {
+		return nil, fmt.Errorf("Error parsing http response: %s", err)
+	}
+	if r.Status != 1 {
+		return nil, fmt.Errorf("add failed with status %d: %s", r.Status, respBytes)
+	}
+	// saving a url that's already in the list updates the existing item
+	if client.itemCache != nil {
+		if item, err := decodeItem(r.Item); err == nil && item != nil {
+			client.itemCache.evict(item.ItemID)
+		}
+	}
+	return respBytes, nil
 }
 
-func (client *Client) Modify(req *ModifyRequest) (map[string]interface{}, error) {
-	if err := client.verifyAccessToken(); err != nil {
+func (client *Client) modify(ctx context.Context, req *ModifyRequest) ([]byte, error) {
+	if req == nil {
+		return nil, ErrNilRequest
+	}
+	if err := client.verifyCredentials(); err != nil {
 		return nil, err
 	}
+	if client.maxActions > 0 && len(req.actions) > client.maxActions {
+		return nil, &TooManyActionsError{Count: len(req.actions), Max: client.maxActions}
+	}
 
 	var l []interface{}
 	for _, a := range req.actions {
@@ -329,6 +730,18 @@ func (client *Client) Modify(req *ModifyRequest) (map[string]interface{}, error)
 		for k, v := range a.Params {
 			m[k] = v
 		}
+		if tags, ok := m["tags"]; ok && client.normalizeTags {
+			tags = strings.Join(client.tagList(strings.Split(tags, ",")), ",")
+			switch {
+			case len(tags) > 0:
+				m["tags"] = tags
+			case a.Kind == ActionAdd:
+				delete(m, "tags")
+			default:
+				// an empty tags_replace would clear the item's tags
+				return nil, ErrNoTags
+			}
+		}
 		l = append(l, m)
 	}
 	actionsJson, err := json.Marshal(l)
@@ -338,92 +751,388 @@ func (client *Client) Modify(req *ModifyRequest) (map[string]interface{}, error)
 
 	params := url.Values{}
 	params.Set("consumer_key", client.ConsumerToken)
-	params.Set("access_token", client.AccessToken)
+	params.Set("access_token", client.accessToken())
 	params.Set("actions", string(actionsJson[:]))
 
 	encodedUrl := fmt.Sprintf("%s?%s", modifyUrl, params.Encode())
 
-	resp, err := client.c.Get(encodedUrl)
-	if err != nil {
-		return nil, err
-	}
-	respBytes, err := client.handleResp(resp)
-	if err != nil {
-		return nil, err
+	// small batches go out as a GET, large ones as a POST with a JSON body so
+	// that they don't run into url length limits along the way
+	var respBytes []byte
+	if len(encodedUrl) < client.modifyGetThreshold {
+		respBytes, err = client.send(ctx, "GET", encodedUrl, "", nil)
+	} else {
+		body, jsonErr := json.Marshal(map[string]interface{}{
+			"consumer_key": client.ConsumerToken,
+			"access_token": client.accessToken(),
+			"actions":      l,
+		})
+		if jsonErr != nil {
+			return nil, jsonErr
+		}
+		respBytes, err = client.send(ctx, "POST", modifyUrl, "application/json", body)
 	}
+	// even a failed batch may have been applied in part
+	client.itemCache.evictActions(req)
+	return respBytes, err
+}
 
-	var r interface{}
-	if err := json.Unmarshal(respBytes, &r); err != nil {
-		return nil, fmt.Errorf("Error parsing http response: %s", err)
+// tagList applies the client's tag normalization, if enabled: tags are
+// trimmed, optionally lowercased, and empty or duplicate ones are dropped.
+func (client *Client) tagList(tags []string) []string {
+	if !client.normalizeTags {
+		return tags
 	}
 
-	m := r.(map[string]interface{})
-	return m, nil
+	seen := make(map[string]bool, len(tags))
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if client.lowercaseTags {
+			tag = strings.ToLower(tag)
+		}
+		if len(tag) == 0 || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
 }
 
-// private methods
+// normalizeTag applies the client's tag normalization to a single tag. It
+// returns "" for a tag that would be dropped.
+func (client *Client) normalizeTag(tag string) string {
+	if tags := client.tagList([]string{tag}); len(tags) > 0 {
+		return tags[0]
+	}
+	return ""
+}
 
-func (client *Client) verifyAccessToken() error {
-	if len(client.AccessToken) > 0 {
+// verifyCredentials checks that the client has what every item call needs,
+// so that a misconfigured client fails with a clear error instead of a 4xx.
+func (client *Client) verifyCredentials() error {
+	if len(client.ConsumerToken) == 0 {
+		return ErrMissingConsumerKey
+	}
+	if len(client.accessToken()) > 0 {
 		return nil
 	} else {
 		return fmt.Errorf("missing access token")
 	}
 }
 
-func (client *Client) performPost(requestUrl string, params url.Values) (string, error) {
-	var respStr string
-	resp, err := client.c.PostForm(requestUrl, params)
-	if err != nil {
-		return respStr, err
-	} else {
-		respBytes, err := client.handleResp(resp)
-		respStr = string(respBytes[:])
-		return respStr, err
+func (client *Client) rememberRedirectUri(redirectUri string) string {
+	if len(redirectUri) == 0 {
+		return client.redirectUri
 	}
+	client.redirectUri = redirectUri
+	return redirectUri
 }
 
-func (client *Client) performPostJson(
-	requestUrl string, params map[string]string) (map[string]interface{}, error) {
-	paramsEncoded, err := json.Marshal(params)
+// findSavedItem searches the user's list (in every state) for an item saved
+// with the given url. It returns nil if there is no such item.
+func (client *Client) findSavedItem(ctx context.Context, itemUrl string) (map[string]interface{}, error) {
+	req := NewRetrieveRequest().OnlyState(StateAll).Search(itemUrl)
+	respBytes, err := client.retrieve(ctx, req)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := client.c.Post(requestUrl, "application/json", bytes.NewReader(paramsEncoded))
+	m, err := decodeJsonMap(respBytes)
 	if err != nil {
 		return nil, err
-	} else {
-		respBytes, err := client.handleResp(resp)
-		if err != nil {
-			return nil, err
+	}
+
+	// pocket returns an empty array rather than an object when nothing matches
+	list, _ := m["list"].(map[string]interface{})
+	for _, v := range list {
+		item, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if item["given_url"] == itemUrl || item["resolved_url"] == itemUrl {
+			return item, nil
+		}
+	}
+	return nil, nil
+}
+
+func (client *Client) performPost(ctx context.Context, requestUrl string, params url.Values) (string, error) {
+	respBytes, err := client.send(ctx, "POST", requestUrl, "application/x-www-form-urlencoded",
+		[]byte(params.Encode()))
+	return string(respBytes[:]), err
+}
+
+// postJson posts params as a JSON object, or form encoded with
+// WithFormEncoding. encoding/json (like url.Values.Encode) writes keys in
+// sorted order, so the same params always produce the same body; request
+// recordings and snapshot tests rely on that.
+func (client *Client) postJson(ctx context.Context, requestUrl string, params map[string]string) ([]byte, error) {
+	respBytes, _, err := client.doPostJson(ctx, requestUrl, params)
+	return respBytes, err
+}
+
+// doPostJson is postJson, also returning the response headers.
+func (client *Client) doPostJson(ctx context.Context,
+	requestUrl string, params map[string]string) ([]byte, http.Header, error) {
+	if client.formEncoding {
+		v := url.Values{}
+		for k, p := range params {
+			v.Set(k, p)
+		}
+		return client.do(ctx, "POST", requestUrl, "application/x-www-form-urlencoded", []byte(v.Encode()))
+	}
+
+	paramsEncoded, err := json.Marshal(params)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client.do(ctx, "POST", requestUrl, "application/json", paramsEncoded)
+}
+
+func decodeJsonMap(respBytes []byte) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(respBytes, &m); err != nil {
+		return nil, fmt.Errorf("Error parsing http response: %s", err)
+	}
+	return m, nil
+}
+
+func (client *Client) send(ctx context.Context,
+	method string, requestUrl string, contentType string, body []byte) ([]byte, error) {
+	respBytes, _, err := client.do(ctx, method, requestUrl, contentType, body)
+	return respBytes, err
+}
+
+// do is send, also returning the response headers. Calls to the item
+// endpoints are reported to the metrics sink set with WithMetrics.
+func (client *Client) do(ctx context.Context,
+	method string, requestUrl string, contentType string, body []byte) ([]byte, http.Header, error) {
+	op := apiOperation(requestUrl)
+	if client.metrics == nil || len(op) == 0 {
+		return client.doRetrying(ctx, method, requestUrl, contentType, body)
+	}
+	start := client.clock.Now()
+	respBytes, header, err := client.doRetrying(ctx, method, requestUrl, contentType, body)
+	client.metrics(op, client.clock.Now().Sub(start), err)
+	return respBytes, header, err
+}
+
+// doRetrying sends a request, retrying failed attempts as configured with
+// WithRetry.
+func (client *Client) doRetrying(ctx context.Context,
+	method string, requestUrl string, contentType string, body []byte) ([]byte, http.Header, error) {
+	ctx = client.withRetryBudget(ctx)
+	token, reauthed := client.accessToken(), false
+	for attempt := 0; ; attempt++ {
+		respBytes, resp, err := client.doOnce(ctx, method, requestUrl, contentType, body)
+		var header http.Header
+		if resp != nil {
+			header = resp.Header
 		}
+		if client.reauth != nil && !reauthed && len(token) > 0 && errors.Is(err, ErrInvalidToken) {
+			// the token is baked into the request, so swap in the new one
+			newToken, err := client.reauthorize(ctx, token)
+			if err != nil {
+				return nil, nil, err
+			}
+			requestUrl = string(replaceToken([]byte(requestUrl), token, newToken))
+			body = replaceToken(body, token, newToken)
+			token, reauthed = newToken, true
+			attempt--
+			continue
+		}
+		if err == nil || attempt >= client.retry.MaxRetries || !client.shouldRetry(ctx, resp, err) {
+			return respBytes, header, err
+		}
+		delay := client.retryDelay(attempt, header, err)
+		if !client.withinBudget(ctx, delay) {
+			return respBytes, header, err
+		}
+		if err := client.wait(ctx, delay); err != nil {
+			return nil, nil, err
+		}
+	}
+}
 
-		var r interface{}
-		if err := json.Unmarshal(respBytes, &r); err != nil {
-			return nil, fmt.Errorf("Error parsing http response: %s", err)
+// doOnce sends a single request. The response is returned, with its body
+// already read and closed, whenever pocket answered.
+func (client *Client) doOnce(ctx context.Context,
+	method string, requestUrl string, contentType string, body []byte) ([]byte, *http.Response, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, requestUrl, bodyReader)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the item endpoints answer with JSON; WithHeader can override this
+	if len(apiOperation(requestUrl)) > 0 {
+		httpReq.Header.Set("Accept", "application/json")
+	}
+	// custom headers go first so they can't replace the ones set below
+	for k, vs := range client.headers {
+		httpReq.Header[k] = append([]string(nil), vs...)
+	}
+	if len(client.requestIdHeader) > 0 {
+		if id := contextString(ctx, client.requestIdKey); len(id) > 0 {
+			httpReq.Header.Set(client.requestIdHeader, id)
 		}
+	}
+	if len(contentType) > 0 {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := client.c.Do(httpReq)
+	if err != nil {
+		return nil, nil, err
+	}
+	client.quota.update(resp.Header)
+	respBytes, err := client.handleResp(resp)
+	return respBytes, resp, err
+}
 
-		m := r.(map[string]interface{})
-		return m, nil
+// apiOperation names the item endpoint requestUrl calls ("retrieve", "add" or
+// "modify"), or returns "" for any other url.
+func apiOperation(requestUrl string) string {
+	switch {
+	case strings.HasPrefix(requestUrl, retrieveUrl):
+		return "retrieve"
+	case strings.HasPrefix(requestUrl, addUrl):
+		return "add"
+	case strings.HasPrefix(requestUrl, modifyUrl):
+		return "modify"
 	}
+	return ""
 }
 
 func (client *Client) handleResp(resp *http.Response) ([]byte, error) {
-	respBytes, err := ioutil.ReadAll(resp.Body)
+	var body io.Reader = resp.Body
+	if client.progress != nil {
+		body = &progressReader{r: resp.Body, total: resp.ContentLength, fn: client.progress}
+	}
+	respBytes, err := ioutil.ReadAll(body)
 	defer resp.Body.Close()
 	if err != nil {
 		return respBytes, fmt.Errorf("Error parsing http response body: %s", err)
 	}
+	contentType := resp.Header.Get("Content-Type")
 
 	if resp.StatusCode == 200 {
+		if respBytes, err = toUTF8(respBytes, contentType); err != nil {
+			return nil, err
+		}
+		client.captureResponseHeaders(resp.Header)
 		return respBytes, nil
 	} else {
+		// an error body is only mined for a message, so an unsupported
+		// charset mustn't hide the *Error
+		if b, err := toUTF8(respBytes, contentType); err == nil {
+			respBytes = b
+		}
 		pErr := &Error{StatusCode: resp.StatusCode}
 		if errCodeStr := resp.Header.Get("X-Error-Code"); len(errCodeStr) > 0 {
 			pErr.ErrorCode, err = strconv.Atoi(errCodeStr)
 		}
 		pErr.ErrorMsg = resp.Header.Get("X-Error")
+		if pErr.ErrorCode == 0 || len(pErr.ErrorMsg) == 0 {
+			code, msg := bodyError(respBytes)
+			if pErr.ErrorCode == 0 {
+				pErr.ErrorCode = code
+			}
+			if len(pErr.ErrorMsg) == 0 {
+				pErr.ErrorMsg = msg
+			}
+		}
+		if len(pErr.ErrorMsg) == 0 {
+			pErr.ErrorMsg = ErrorDescriptions[pErr.ErrorCode]
+		}
+		pErr.kind = errorKind(resp)
 
 		return respBytes, pErr
 	}
 }
+
+func (client *Client) captureResponseHeaders(header http.Header) {
+	if len(client.captureHeaders) == 0 {
+		return
+	}
+	captured := make(http.Header)
+	for _, name := range client.captureHeaders {
+		if vs := header.Values(name); len(vs) > 0 {
+			captured[http.CanonicalHeaderKey(name)] = append([]string(nil), vs...)
+		}
+	}
+	client.headersMu.Lock()
+	client.lastHeaders = captured
+	client.headersMu.Unlock()
+}
+
+// errorBodyJson covers the shapes in which error details turn up in a JSON
+// body: {"error": "...", "error_code": n}, {"message": "...", "code": n} and
+// {"error": {"message": "...", "code": n}}.
+type errorBodyJson struct {
+	Error     json.RawMessage `json:"error"`
+	ErrorCode flexString      `json:"error_code"`
+	Message   flexString      `json:"message"`
+	Code      flexString      `json:"code"`
+}
+
+// bodyError extracts an error code and message from a JSON error body, for
+// the errors pocket reports there rather than in the X-Error headers.
+func bodyError(body []byte) (int, string) {
+	var j errorBodyJson
+	if err := json.Unmarshal(body, &j); err != nil {
+		return 0, ""
+	}
+	code, msg := atoi(string(j.ErrorCode)), string(j.Message)
+	if code == 0 {
+		code = atoi(string(j.Code))
+	}
+
+	var nested errorBodyJson
+	if err := json.Unmarshal(j.Error, &nested); err == nil {
+		if code == 0 {
+			code = atoi(string(nested.Code))
+		}
+		if len(msg) == 0 {
+			msg = string(nested.Message)
+		}
+	} else {
+		var s flexString
+		if err := json.Unmarshal(j.Error, &s); err == nil && len(s) > 0 {
+			msg = string(s)
+		}
+	}
+	return code, msg
+}
+
+// contextString returns the value stored in ctx under key if it is a string
+// or a fmt.Stringer, and "" otherwise.
+func contextString(ctx context.Context, key interface{}) string {
+	switch v := ctx.Value(key).(type) {
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	}
+	return ""
+}
+
+// progressReader reports the bytes read so far to fn after every read.
+type progressReader struct {
+	r     io.Reader
+	read  int64
+	total int64
+	fn    func(read, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		p.fn(p.read, p.total)
+	}
+	return n, err
+}
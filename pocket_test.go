@@ -0,0 +1,589 @@
+package pocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAddSkipIfExists(t *testing.T) {
+	p := newFakePocket(t, `{"item_id":"1","given_url":"https://example.com/saved"}`)
+	client := p.client()
+
+	m, err := client.Add(new(AddRequest).SetUrl("https://example.com/saved").
+		SetOptions(SaveOptions{SkipIfExists: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := m["item"].(map[string]interface{})["item_id"]; id != "1" {
+		t.Errorf("got item %v, want the saved item 1", id)
+	}
+	if len(p.adds) != 0 {
+		t.Errorf("saved the url again: %v", p.adds)
+	}
+	if len(p.retrieves) != 1 || p.retrieves[0]["search"] != "https://example.com/saved" ||
+		p.retrieves[0]["state"] != "all" {
+		t.Errorf("looked the url up with %v, want a search in every state", p.retrieves)
+	}
+
+	if _, err := client.Add(new(AddRequest).SetUrl("https://example.com/new").
+		SetOptions(SaveOptions{SkipIfExists: true})); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.adds) != 1 || p.adds[0]["url"] != "https://example.com/new" {
+		t.Errorf("got adds %v, want the new url saved", p.adds)
+	}
+}
+
+func TestSkipIfExistsUsesContext(t *testing.T) {
+	p := newFakePocket(t)
+	var ids []string
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.URL.Path+" "+r.Header.Get("X-Request-Id"))
+		p.serveHTTP(w, r)
+	}, WithRequestIdHeader("X-Request-Id", requestIdKey{}))
+	req := new(AddRequest).SetUrl("https://example.com/new").SetOptions(SaveOptions{SkipIfExists: true})
+
+	ctx := context.WithValue(context.Background(), requestIdKey{}, "req-42")
+	if _, err := client.QuickAdd(ctx, "https://example.com/quick"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.add(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/v3/add req-42", "/v3/get req-42", "/v3/add req-42"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("sent %q, want the lookup made with the caller's context %q", ids, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ids = nil
+	if _, err := client.add(ctx, req); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled: got %v, want context.Canceled", err)
+	}
+	if len(ids) != 0 {
+		t.Errorf("cancelled: sent %q", ids)
+	}
+}
+
+func TestRedirectURIReuse(t *testing.T) {
+	var redirects []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		redirects = append(redirects, requestParams(t, r)["redirect_uri"])
+		fmt.Fprint(w, "code=request-token")
+	}
+
+	t.Run("configured", func(t *testing.T) {
+		redirects = nil
+		client := newTestClient(handler, WithRedirectURI("https://app.example.com/done"))
+		token, err := client.NewRequestToken("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(redirects) != 1 || redirects[0] != "https://app.example.com/done" {
+			t.Errorf("requested token with redirect %v", redirects)
+		}
+		authUrl := client.GetAuthorizationUrl(token, "")
+		if !strings.Contains(authUrl, url.QueryEscape("https://app.example.com/done")) {
+			t.Errorf("authorization url %s lacks the configured redirect uri", authUrl)
+		}
+	})
+
+	t.Run("remembered", func(t *testing.T) {
+		client := newTestClient(handler)
+		token, err := client.NewRequestToken("https://app.example.com/other")
+		if err != nil {
+			t.Fatal(err)
+		}
+		authUrl := client.GetAuthorizationUrl(token, "")
+		if !strings.Contains(authUrl, url.QueryEscape("https://app.example.com/other")) {
+			t.Errorf("authorization url %s lacks the redirect uri of NewRequestToken", authUrl)
+		}
+	})
+}
+
+func TestAddFailedStatus(t *testing.T) {
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":0,"item":false}`)
+	})
+	req := new(AddRequest).SetUrl("https://example.com/a")
+
+	if _, err := client.Add(req); err == nil || !strings.Contains(err.Error(), `"status":0`) {
+		t.Errorf("Add: got %v, want an error with the response body", err)
+	}
+	if _, err := client.AddTyped(req); err == nil {
+		t.Error("AddTyped: got no error")
+	}
+}
+
+func TestRetrieveRequestReset(t *testing.T) {
+	req := NewRetrieveRequest().OnlyTag("go").Sort(SortOldest).Count(10).
+		PageSize(5).Limit(20).ResumeAt(3).OnlyWithAnnotations()
+	req.Reset()
+	if len(req.params) != 0 {
+		t.Errorf("params %v left after Reset", req.params)
+	}
+	if req.pageSize != 0 || req.limit != 0 || req.startOffset != 0 || req.onlyAnnotated {
+		t.Errorf("settings left after Reset: %+v", req)
+	}
+
+	req.OnlyState(StateArchive)
+	if want := map[string]string{"state": "archive"}; !reflect.DeepEqual(req.params, want) {
+		t.Errorf("reused request has params %v, want %v", req.params, want)
+	}
+}
+
+func TestSearchWithSort(t *testing.T) {
+	p := newFakePocket(t)
+	if _, err := p.client().Retrieve(NewRetrieveRequest().Search("golang").Sort(SortOldest)); err != nil {
+		t.Fatal(err)
+	}
+	if params := p.retrieves[0]; params["search"] != "golang" || params["sort"] != "oldest" {
+		t.Errorf("got params %v, want search and sort", params)
+	}
+}
+
+func TestUnreadArticles(t *testing.T) {
+	want := map[string]string{"state": "unread", "contentType": "article", "sort": "newest"}
+	if got := UnreadArticles().params; !reflect.DeepEqual(got, want) {
+		t.Errorf("got params %v, want %v", got, want)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	body := `{"status":1,"list":[]}` + strings.Repeat(" ", 10000)
+	var last int64
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		fmt.Fprint(w, body)
+	}, WithProgress(func(read, total int64) {
+		if total != int64(len(body)) {
+			t.Errorf("got total %d, want %d", total, len(body))
+		}
+		if read > total {
+			t.Errorf("read %d of %d", read, total)
+		}
+		last = read
+	}))
+	if _, err := client.Retrieve(NewRetrieveRequest()); err != nil {
+		t.Fatal(err)
+	}
+	if last != int64(len(body)) {
+		t.Errorf("last reported %d bytes read, want %d", last, len(body))
+	}
+}
+
+func TestTagNormalization(t *testing.T) {
+	tags := []string{"go", "Go", " go ", "", "web"}
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"off", nil, "go,Go, go ,,web"},
+		{"trim", []Option{WithTagNormalization(false)}, "go,Go,web"},
+		{"lowercase", []Option{WithTagNormalization(true)}, "go,web"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newFakePocket(t)
+			client := p.client(tt.opts...)
+			if _, err := client.Add(new(AddRequest).SetUrl("https://example.com").AddTags(tags)); err != nil {
+				t.Fatal(err)
+			}
+			if got := p.adds[0]["tags"]; got != tt.want {
+				t.Errorf("Add sent tags %q, want %q", got, tt.want)
+			}
+
+			req := new(ModifyRequest)
+			req.AddAction(NewTagsAddAction("1", tags))
+			if _, err := client.Modify(req); err != nil {
+				t.Fatal(err)
+			}
+			if got := p.modifies[0][0]["tags"]; got != tt.want {
+				t.Errorf("tags_add sent tags %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestBodyIsDeterministic(t *testing.T) {
+	var bodies []string
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		fmt.Fprint(w, `{"status":1,"list":[]}`)
+	})
+	for i := 0; i < 20; i++ {
+		req := NewRetrieveRequest().OnlyState(StateAll).OnlyTag("go").Sort(SortOldest).
+			OnlyFavorited().Search("x").Count(10).Offset(5).CompleteItemInfo()
+		if _, err := client.Retrieve(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := `{"access_token":"access-token","consumer_key":"consumer-key","count":"10",` +
+		`"detailType":"complete","favorite":"1","offset":"5","search":"x","sort":"oldest",` +
+		`"state":"all","tag":"go"}`
+	for _, body := range bodies {
+		if body != want {
+			t.Fatalf("got body %s, want %s", body, want)
+		}
+	}
+}
+
+func TestRetrieveRequestClone(t *testing.T) {
+	base := NewRetrieveRequest().OnlyTag("go").PageSize(10)
+	clone := base.Clone().OnlyTag("rust").Count(5).PageSize(20)
+
+	if want := map[string]string{"tag": "go"}; !reflect.DeepEqual(base.params, want) || base.pageSize != 10 {
+		t.Errorf("base changed to %v, page size %d", base.params, base.pageSize)
+	}
+	if want := map[string]string{"tag": "rust", "count": "5"}; !reflect.DeepEqual(clone.params, want) {
+		t.Errorf("clone has %v, want %v", clone.params, want)
+	}
+}
+
+func TestCaptureHeaders(t *testing.T) {
+	status := http.StatusOK
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Source", "Pocket")
+		w.Header().Set("X-Other", "ignored")
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"status":1,"list":[]}`)
+	}, WithCaptureHeaders("x-source"))
+
+	if client.ResponseHeaders() != nil {
+		t.Error("got headers before any response")
+	}
+	if _, err := client.Retrieve(NewRetrieveRequest()); err != nil {
+		t.Fatal(err)
+	}
+	want := http.Header{"X-Source": {"Pocket"}}
+	if got := client.ResponseHeaders(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	status = http.StatusBadRequest
+	client.Retrieve(NewRetrieveRequest())
+	if got := client.ResponseHeaders(); !reflect.DeepEqual(got, want) {
+		t.Errorf("an error response changed the headers to %v", got)
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	tests := []struct {
+		response  string
+		want      []string
+		canModify bool
+	}{
+		{"access_token=t&username=u", nil, true},
+		{"access_token=t&username=u&permissions=add,retrieve", []string{"add", "retrieve"}, false},
+		{"access_token=t&username=u&permissions=add,+modify", []string{"add", "modify"}, true},
+	}
+	for _, tt := range tests {
+		client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, tt.response)
+		})
+		if err := client.FetchAccessToken("request-token"); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(client.Permissions, tt.want) || client.CanModify() != tt.canModify {
+			t.Errorf("%s: got %q, CanModify %v", tt.response, client.Permissions, client.CanModify())
+		}
+	}
+}
+
+func TestURLResolver(t *testing.T) {
+	resolver := func(ctx context.Context, shortUrl string) (string, error) {
+		if shortUrl == "https://t.co/broken" {
+			return "", errors.New("no redirect")
+		}
+		return strings.Replace(shortUrl, "https://t.co/", "https://example.com/", 1), nil
+	}
+	p := newFakePocket(t)
+	client := p.client(WithURLResolver(resolver))
+
+	item, err := client.QuickAdd(context.Background(), "https://t.co/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.adds[0]["url"] != "https://example.com/abc" || item.GivenURL != "https://example.com/abc" {
+		t.Errorf("saved %v, want the resolved url", p.adds[0])
+	}
+
+	if _, err := client.Add(new(AddRequest).SetUrl("https://t.co/broken")); err == nil {
+		t.Error("got no error from a failed resolve")
+	}
+	if len(p.adds) != 1 {
+		t.Error("saved the url even though resolving it failed")
+	}
+}
+
+func TestFormEncoding(t *testing.T) {
+	for _, form := range []bool{false, true} {
+		t.Run(fmt.Sprintf("form=%v", form), func(t *testing.T) {
+			var contentTypes []string
+			var params []map[string]string
+			opts := []Option{}
+			if form {
+				opts = append(opts, WithFormEncoding())
+			}
+			client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+				contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+				params = append(params, requestParams(t, r))
+				if r.URL.Path == "/v3/add" {
+					fmt.Fprint(w, `{"status":1,"item":{"item_id":"1"}}`)
+				} else {
+					fmt.Fprint(w, `{"status":1,"list":[]}`)
+				}
+			}, opts...)
+
+			if _, err := client.Add(new(AddRequest).SetUrl("https://example.com/a?b=c&d").SetTitle("A & B")); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := client.Retrieve(NewRetrieveRequest().OnlyTag("go")); err != nil {
+				t.Fatal(err)
+			}
+
+			want := "application/json"
+			if form {
+				want = "application/x-www-form-urlencoded"
+			}
+			for _, ct := range contentTypes {
+				if ct != want {
+					t.Errorf("sent Content-Type %q, want %q", ct, want)
+				}
+			}
+			if params[0]["url"] != "https://example.com/a?b=c&d" || params[0]["title"] != "A & B" ||
+				params[0]["access_token"] != "access-token" {
+				t.Errorf("add sent %v", params[0])
+			}
+			if params[1]["tag"] != "go" {
+				t.Errorf("retrieve sent %v", params[1])
+			}
+		})
+	}
+}
+
+func TestNilRequests(t *testing.T) {
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("sent %s", r.URL)
+	})
+	calls := map[string]func() error{
+		"Retrieve":      func() error { _, err := client.Retrieve(nil); return err },
+		"RetrieveTyped": func() error { _, err := client.RetrieveTyped(nil); return err },
+		"Add":           func() error { _, err := client.Add(nil); return err },
+		"AddTyped":      func() error { _, err := client.AddTyped(nil); return err },
+		"Modify":        func() error { _, err := client.Modify(nil); return err },
+		"ModifyTyped":   func() error { _, err := client.ModifyTyped(nil); return err },
+		"ModifyAtomic":  func() error { _, err := client.ModifyAtomic(nil); return err },
+		"ModifyChunked": func() error { _, err := client.ModifyChunked(context.Background(), nil, 0); return err },
+		"RetrieveAllItems": func() error {
+			_, err := client.RetrieveAllItems(context.Background(), nil)
+			return err
+		},
+		"Iterate": func() error {
+			it := client.Iterate(context.Background(), nil)
+			defer it.Close()
+			if it.Next() {
+				t.Error("Iterate(nil) yielded an item")
+			}
+			return it.Err()
+		},
+		"ArchiveMatching": func() error { _, err := client.ArchiveMatching(context.Background(), nil); return err },
+		"ReaddMatching":   func() error { _, err := client.ReaddMatching(context.Background(), nil); return err },
+		"Watch": func() error {
+			return client.Watch(context.Background(), nil, time.Second, func([]Item) {})
+		},
+	}
+	for name, call := range calls {
+		if err := call(); err != ErrNilRequest {
+			t.Errorf("%s(nil): got %v, want ErrNilRequest", name, err)
+		}
+	}
+}
+
+func TestGetAuthorizationURLChecked(t *testing.T) {
+	client := NewClient("consumer-key")
+	if _, err := client.GetAuthorizationURLChecked("", "https://app.example.com"); err == nil {
+		t.Error("empty request token: got no error")
+	}
+	if _, err := client.GetAuthorizationURLChecked("request-token", ""); err == nil {
+		t.Error("no redirect uri: got no error")
+	}
+
+	got, err := client.GetAuthorizationURLChecked("request-token", "https://app.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := client.GetAuthorizationUrl("request-token", "https://app.example.com"); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	// the redirect uri is remembered now
+	if _, err := client.GetAuthorizationURLChecked("request-token", ""); err != nil {
+		t.Errorf("remembered redirect uri: %v", err)
+	}
+}
+
+func TestCountZero(t *testing.T) {
+	if _, ok := NewRetrieveRequest().Count(0).params["count"]; ok {
+		t.Error("Count(0) sent a count")
+	}
+	if _, ok := NewRetrieveRequest().Count(5).Count(0).params["count"]; ok {
+		t.Error("Count(0) kept an earlier count")
+	}
+	if got := NewRetrieveRequest().Count(5).params["count"]; got != "5" {
+		t.Errorf("Count(5) sent %q", got)
+	}
+}
+
+func TestMissingConsumerKey(t *testing.T) {
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("sent %s", r.URL)
+	})
+	client.ConsumerToken = ""
+	if _, err := client.Retrieve(NewRetrieveRequest()); err != ErrMissingConsumerKey {
+		t.Errorf("Retrieve: got %v, want ErrMissingConsumerKey", err)
+	}
+	if _, err := client.Add(new(AddRequest).SetUrl("https://example.com")); err != ErrMissingConsumerKey {
+		t.Errorf("Add: got %v, want ErrMissingConsumerKey", err)
+	}
+	if _, err := client.ArchiveMany(context.Background(), []string{"1"}); err != ErrMissingConsumerKey {
+		t.Errorf("ArchiveMany: got %v, want ErrMissingConsumerKey", err)
+	}
+}
+
+func TestFromDomain(t *testing.T) {
+	want := map[string]string{"domain": "example.com", "state": "archive"}
+	if got := FromDomain("example.com", StateArchive).params; !reflect.DeepEqual(got, want) {
+		t.Errorf("got params %v, want %v", got, want)
+	}
+}
+
+func TestAddRequestValidate(t *testing.T) {
+	tests := []struct {
+		url       string
+		addScheme bool
+		want      string
+		wantErr   bool
+	}{
+		{"https://example.com/a", false, "https://example.com/a", false},
+		{"http://example.com/a", false, "http://example.com/a", false},
+		{"//example.com/a", false, "https://example.com/a", false},
+		{"www.example.com/a", false, "", true},
+		{"www.example.com/a", true, "https://www.example.com/a", false},
+		{"www.example.com/?next=http://x", false, "", true},
+		{"www.example.com/?next=http://x", true, "https://www.example.com/?next=http://x", false},
+		{"example.com:8080/a", false, "", true},
+		{"example.com:8080/a", true, "https://example.com:8080/a", false},
+		{"https://", false, "", true},
+		{"https://exa mple.com", true, "", true},
+		{"", true, "", true},
+	}
+	for _, tt := range tests {
+		req := new(AddRequest).SetUrl(tt.url).SetOptions(SaveOptions{AddMissingScheme: tt.addScheme})
+		err := req.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v", tt.url, err)
+			continue
+		}
+		if err == nil && req.url != tt.want {
+			t.Errorf("%q: got url %q, want %q", tt.url, req.url, tt.want)
+		}
+	}
+
+	p := newFakePocket(t)
+	if _, err := p.client().Add(new(AddRequest).SetUrl("www.example.com")); err == nil {
+		t.Error("Add of a url without a scheme: got no error")
+	}
+	if len(p.adds) != 0 {
+		t.Error("saved a url without a scheme")
+	}
+}
+
+func TestRetrieveRequestValidate(t *testing.T) {
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("sent %s", r.URL)
+	})
+	_, err := client.Retrieve(NewRetrieveRequest().OnlyTag("").PageSize(-1))
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) {
+		t.Fatalf("got %v, want a *ValidationError", err)
+	}
+	want := []string{"tag is empty", "page size -1 is negative"}
+	if !reflect.DeepEqual(vErr.Problems, want) {
+		t.Errorf("got problems %q, want %q", vErr.Problems, want)
+	}
+	if err.Error() != "invalid request: tag is empty; page size -1 is negative" {
+		t.Errorf("got message %q", err)
+	}
+
+	if err := NewRetrieveRequest().OnlyTag("go").Count(10).Offset(20).Validate(); err != nil {
+		t.Errorf("valid request: %v", err)
+	}
+}
+
+func TestOffsetWithoutCount(t *testing.T) {
+	err := NewRetrieveRequest().Offset(10).Validate()
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) || !reflect.DeepEqual(vErr.Problems, []string{"offset is ignored without a count"}) {
+		t.Errorf("got %v, want the offset rejected", err)
+	}
+	if err := NewRetrieveRequest().Offset(10).Count(5).Validate(); err != nil {
+		t.Errorf("with a count: %v", err)
+	}
+	if err := NewRetrieveRequest().Offset(0).Validate(); err != nil {
+		t.Errorf("offset 0: %v", err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	type metric struct {
+		op  string
+		dur time.Duration
+		err bool
+	}
+	var metrics []metric
+	clock := newFakeClock()
+	failures := 1
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/get":
+			if failures > 0 {
+				failures--
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, `{"status":1,"list":[]}`)
+		case "/v3/add":
+			w.WriteHeader(http.StatusBadRequest)
+		case "/v3/send":
+			fmt.Fprint(w, `{"status":1,"action_results":[true]}`)
+		default:
+			fmt.Fprint(w, "code=request-token")
+		}
+	}, WithClock(clock), WithRetry(RetryOptions{MaxRetries: 1, BaseDelay: time.Second}),
+		WithMetrics(func(op string, dur time.Duration, err error) {
+			metrics = append(metrics, metric{op, dur, err != nil})
+		}))
+
+	client.Retrieve(NewRetrieveRequest())
+	client.Add(new(AddRequest).SetUrl("https://example.com"))
+	client.ArchiveMany(context.Background(), []string{"1"})
+	client.NewRequestToken("https://app.example.com")
+
+	want := []metric{{"retrieve", time.Second, false}, {"add", 0, true}, {"modify", 0, false}}
+	if !reflect.DeepEqual(metrics, want) {
+		t.Errorf("got %+v, want %+v", metrics, want)
+	}
+}
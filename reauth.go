@@ -0,0 +1,53 @@
+package pocket
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+)
+
+// private methods
+
+// reauthorize runs the reauth hook after a request made with token was
+// rejected, and returns the token to retry with. Concurrent requests that
+// fail with the same token only run the hook once.
+func (client *Client) reauthorize(ctx context.Context, token string) (string, error) {
+	client.reauthMu.Lock()
+	defer client.reauthMu.Unlock()
+	if current := client.accessToken(); current != token {
+		return current, nil
+	}
+	newToken, err := client.reauth(ctx)
+	if err != nil {
+		return "", err
+	}
+	client.setAccessToken(newToken)
+	return newToken, nil
+}
+
+// accessToken returns the client's access token. Requests read it through
+// here since a reauth hook may replace it while others are in flight.
+func (client *Client) accessToken() string {
+	client.tokenMu.RLock()
+	defer client.tokenMu.RUnlock()
+	return client.AccessToken
+}
+
+func (client *Client) setAccessToken(token string) {
+	client.tokenMu.Lock()
+	client.AccessToken = token
+	client.tokenMu.Unlock()
+}
+
+// replaceToken swaps the access token in a request url or body, where it
+// appears either as is (JSON) or query escaped (urls and forms).
+func replaceToken(b []byte, oldToken, newToken string) []byte {
+	if b == nil {
+		return nil
+	}
+	b = bytes.Replace(b, []byte(oldToken), []byte(newToken), -1)
+	if escaped := url.QueryEscape(oldToken); escaped != oldToken {
+		b = bytes.Replace(b, []byte(escaped), []byte(url.QueryEscape(newToken)), -1)
+	}
+	return b
+}
@@ -0,0 +1,84 @@
+package pocket
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReauth(t *testing.T) {
+	var tokens []string
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		token := requestParams(t, r)["access_token"]
+		tokens = append(tokens, token)
+		if token != "new-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"status":1,"list":[]}`)
+	}, WithReauth(func(ctx context.Context) (string, error) {
+		return "new-token", nil
+	}))
+
+	if _, err := client.Retrieve(NewRetrieveRequest()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"access-token", "new-token"}; !reflect.DeepEqual(tokens, want) {
+		t.Errorf("sent tokens %v, want %v", tokens, want)
+	}
+}
+
+func TestReauthFails(t *testing.T) {
+	calls := 0
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusUnauthorized)
+	}, WithReauth(func(ctx context.Context) (string, error) {
+		return "", errors.New("user said no")
+	}))
+
+	if _, err := client.Retrieve(NewRetrieveRequest()); err == nil || err.Error() != "user said no" {
+		t.Errorf("got %v, want the hook's error", err)
+	}
+	if calls != 1 {
+		t.Errorf("made %d calls, want 1", calls)
+	}
+}
+
+func TestReauthConcurrent(t *testing.T) {
+	p := newFakePocket(t, numberedItems(20)...)
+	var hookCalls int32
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if requestParams(t, r)["access_token"] != "new-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		p.serveHTTP(w, r)
+	}, WithRetrieveConcurrency(4), WithReauth(func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&hookCalls, 1)
+		return "new-token", nil
+	}))
+
+	items, err := client.RetrieveAllItems(context.Background(), NewRetrieveRequest().PageSize(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 20 {
+		t.Errorf("got %d items, want 20", len(items))
+	}
+	if n := atomic.LoadInt32(&hookCalls); n != 1 {
+		t.Errorf("hook called %d times, want once", n)
+	}
+	if client.AccessToken != "new-token" {
+		t.Errorf("client kept token %q", client.AccessToken)
+	}
+}
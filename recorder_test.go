@@ -0,0 +1,46 @@
+package pocket
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	p := newFakePocket(t, numberedItems(2)...)
+	recorded, err := p.client(WithRecorder(dir)).RetrieveTyped(NewRetrieveRequest().OnlyTag("go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(names) != 1 {
+		t.Fatalf("got recordings %v, want one", names)
+	}
+	b, err := os.ReadFile(names[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "access-token") || strings.Contains(string(b), "consumer-key") {
+		t.Errorf("recording has credentials: %s", b)
+	}
+
+	hc, err := ReplayClient(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClientWithAccessToken("consumer-key", "access-token", "user", WithHTTPClient(hc))
+	replayed, err := client.RetrieveTyped(NewRetrieveRequest().OnlyTag("go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := itemIdsOf(replayed.Ordered), itemIdsOf(recorded.Ordered); !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed %v, want %v", got, want)
+	}
+	if _, err := client.RetrieveTyped(NewRetrieveRequest().OnlyTag("go")); err == nil {
+		t.Error("replayed a recording twice")
+	}
+}
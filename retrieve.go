@@ -0,0 +1,297 @@
+package pocket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetrieveResponse is the typed result of a retrieve call. List is keyed by
+// item id; use Items for the items in the order pocket returned them.
+type RetrieveResponse struct {
+	Status   int
+	Complete int
+	Since    int64
+	List     map[string]Item
+
+	// Ordered holds the items of List in the order of Items. RetrieveTyped
+	// fills it in; it isn't updated if List is changed afterwards.
+	Ordered []Item
+
+	// Total is the number of items matching the request regardless of
+	// count/offset. Pocket only sends it when the request asks for it
+	// (total=1); hasTotal records whether it did.
+	Total    int
+	hasTotal bool
+}
+
+func (resp *RetrieveResponse) UnmarshalJSON(b []byte) error {
+	var r struct {
+		Status   int             `json:"status"`
+		Complete int             `json:"complete"`
+		Since    int64           `json:"since"`
+		List     json.RawMessage `json:"list"`
+		Total    *flexString     `json:"total"`
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	resp.Status = r.Status
+	resp.Complete = r.Complete
+	resp.Since = r.Since
+	if r.Total != nil {
+		resp.Total = atoi(string(*r.Total))
+		resp.hasTotal = true
+	}
+	resp.List = make(map[string]Item)
+	// pocket sends an empty array rather than an object when nothing matches
+	if list := bytes.TrimSpace(r.List); len(list) > 0 && list[0] == '{' {
+		var raws map[string]json.RawMessage
+		if err := json.Unmarshal(list, &raws); err != nil {
+			return err
+		}
+		for id, raw := range raws {
+			item, err := decodeItem(raw)
+			if err != nil {
+				return err
+			}
+			if item == nil {
+				continue
+			}
+			if len(item.ItemID) == 0 {
+				item.ItemID = id
+			}
+			resp.List[id] = *item
+		}
+	}
+	return nil
+}
+
+// ErrNoSortId is returned by SortedItems when pocket didn't send sort_id for
+// every item, so the order may differ from the one pocket shows.
+var ErrNoSortId = errors.New("items have no sort_id; ordered by time added")
+
+// Items returns the items of the response ordered by pocket's sort_id. If
+// some item has no sort_id, they're ordered by time added, newest first
+// instead; use SortedItems to find out.
+func (resp *RetrieveResponse) Items() []Item {
+	items, _ := resp.SortedItems()
+	return items
+}
+
+// SortedItems is Items, also returning ErrNoSortId along with the items when
+// it had to fall back to ordering them by time added.
+func (resp *RetrieveResponse) SortedItems() ([]Item, error) {
+	items := make([]Item, 0, len(resp.List))
+	hasSortIds := true
+	for _, item := range resp.List {
+		items = append(items, item)
+		hasSortIds = hasSortIds && item.hasSortId
+	}
+
+	if !hasSortIds {
+		sort.Slice(items, func(i, j int) bool {
+			if items[i].TimeAdded != items[j].TimeAdded {
+				return items[i].TimeAdded > items[j].TimeAdded
+			}
+			return items[i].ItemID < items[j].ItemID
+		})
+		return items, ErrNoSortId
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].SortID != items[j].SortID {
+			return items[i].SortID < items[j].SortID
+		}
+		return items[i].ItemID < items[j].ItemID
+	})
+	return items, nil
+}
+
+func (client *Client) RetrieveTyped(req *RetrieveRequest) (*RetrieveResponse, error) {
+	resp, err := client.retrieveTyped(context.Background(), req)
+	if err != nil {
+		return nil, err
+	}
+	for id, item := range resp.List {
+		if !req.keep(&item) {
+			delete(resp.List, id)
+		}
+	}
+	resp.Ordered = resp.Items()
+	return resp, nil
+}
+
+// keep reports whether item passes the request's client side filters.
+func (req *RetrieveRequest) keep(item *Item) bool {
+	return !req.onlyAnnotated || len(item.Annotations) > 0
+}
+
+// ItemsNotFoundError is returned by GetItems, along with the items it did
+// find, when some of the requested ids aren't in the user's list.
+type ItemsNotFoundError struct {
+	ItemIds []string
+}
+
+func (e *ItemsNotFoundError) Error() string {
+	return fmt.Sprintf("items not found: %s", strings.Join(e.ItemIds, ", "))
+}
+
+// GetItems fetches the items with the given ids, keyed by id. Pocket can't
+// retrieve specific items, so this pages through the whole list (in every
+// state) until all of them have been seen, unless they're all in the item
+// cache (see WithItemCache). Ids that weren't found are reported in an
+// *ItemsNotFoundError.
+func (client *Client) GetItems(ctx context.Context, itemIds []string) (map[string]Item, error) {
+	now := client.clock.Now()
+	found := make(map[string]Item, len(itemIds))
+	wanted := make(map[string]bool, len(itemIds))
+	for _, id := range itemIds {
+		if item, ok := client.itemCache.get(id, now); ok {
+			found[id] = item
+		} else {
+			wanted[id] = true
+		}
+	}
+
+	if len(wanted) > 0 {
+		it := client.Iterate(ctx, NewRetrieveRequest().OnlyState(StateAll))
+		defer it.Close()
+		for len(wanted) > 0 && it.Next() {
+			if item := it.Item(); wanted[item.ItemID] {
+				found[item.ItemID] = item
+				delete(wanted, item.ItemID)
+				client.itemCache.put(item, now)
+			}
+		}
+		if err := it.Err(); err != nil {
+			return found, err
+		}
+	}
+
+	var missing []string
+	for _, id := range itemIds {
+		if _, ok := found[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return found, &ItemsNotFoundError{ItemIds: missing}
+	}
+	return found, nil
+}
+
+// GetItem fetches the item with the given id, like GetItems.
+func (client *Client) GetItem(ctx context.Context, itemId string) (Item, error) {
+	found, err := client.GetItems(ctx, []string{itemId})
+	return found[itemId], err
+}
+
+// LibrarySize returns how many items the user has in the given state. It
+// asks pocket for the total along with a single item, and only pages through
+// the list to count if pocket doesn't send one.
+func (client *Client) LibrarySize(ctx context.Context, state ItemState) (int, error) {
+	req := NewRetrieveRequest().OnlyState(state).SimpleItemInfo().Count(1)
+	req.params["total"] = "1"
+	resp, err := client.retrieveTyped(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	if resp.hasTotal {
+		return resp.Total, nil
+	}
+
+	size := 0
+	it := client.Iterate(ctx, req.Reset().OnlyState(state).SimpleItemInfo())
+	defer it.Close()
+	for it.Next() {
+		size++
+	}
+	return size, it.Err()
+}
+
+// ModifiedWithin returns a request for the items added or changed in the last
+// d, going by the client's clock, newest first. Add OnlyState(StateAll) to
+// include archived and deleted items.
+func (client *Client) ModifiedWithin(d time.Duration) *RetrieveRequest {
+	since := client.clock.Now().Add(-d).Unix()
+	return NewRetrieveRequest().Since(strconv.FormatInt(since, 10)).Sort(SortNewest)
+}
+
+// retrieveValues lists the query keys RetrieveRequestFromValues accepts and,
+// for the enumerated ones, the values pocket understands.
+var retrieveValues = map[string][]string{
+	"state":       {"unread", "archive", "all"},
+	"favorite":    {"0", "1"},
+	"tag":         nil,
+	"contentType": {"article", "video", "image"},
+	"sort":        {"newest", "oldest", "title", "site"},
+	"detailType":  {"simple", "complete"},
+	"search":      nil,
+	"domain":      nil,
+	"since":       nil,
+	"count":       nil,
+	"offset":      nil,
+}
+
+// RetrieveRequestFromValues builds a request from query params named as in
+// pocket's retrieve API (state, favorite, tag, contentType, sort, detailType,
+// search, domain, since, count and offset), e.g. to pass filters from an
+// http handler through to pocket. Unknown keys, repeated keys and invalid
+// values are errors.
+func RetrieveRequestFromValues(v url.Values) (*RetrieveRequest, error) {
+	req := NewRetrieveRequest()
+	for key, values := range v {
+		allowed, ok := retrieveValues[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown retrieve param %q", key)
+		}
+		if len(values) != 1 {
+			return nil, fmt.Errorf("retrieve param %q given %d times", key, len(values))
+		}
+		value := values[0]
+		switch key {
+		case "count", "offset", "since":
+			if n, err := strconv.Atoi(value); err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid value %q for retrieve param %q", value, key)
+			}
+		default:
+			if allowed != nil && !contains(allowed, value) {
+				return nil, fmt.Errorf("invalid value %q for retrieve param %q", value, key)
+			}
+		}
+		req.params[key] = value
+	}
+	return req, nil
+}
+
+// private methods
+
+func (client *Client) retrieveTyped(ctx context.Context, req *RetrieveRequest) (*RetrieveResponse, error) {
+	respBytes, err := client.retrieve(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(RetrieveResponse)
+	if err := json.Unmarshal(respBytes, resp); err != nil {
+		return nil, fmt.Errorf("Error parsing http response: %s", err)
+	}
+	return resp, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package pocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOnlyWithAnnotations(t *testing.T) {
+	p := newFakePocket(t,
+		`{"item_id":"1","annotations":[{"annotation_id":"a","quote":"q"}]}`,
+		`{"item_id":"2","annotations":[]}`,
+		`{"item_id":"3"}`)
+	resp, err := p.client().RetrieveTyped(NewRetrieveRequest().OnlyWithAnnotations())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := itemIdsOf(resp.Ordered); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Errorf("got %v, want [1]", got)
+	}
+	if p.retrieves[0]["detailType"] != "complete" {
+		t.Errorf("retrieved with %v, want complete detail", p.retrieves[0])
+	}
+}
+
+func TestGetItems(t *testing.T) {
+	p := newFakePocket(t, numberedItems(5)...)
+	found, err := p.client().GetItems(context.Background(), []string{"2", "9", "4"})
+	var notFound *ItemsNotFoundError
+	if !errors.As(err, &notFound) || !reflect.DeepEqual(notFound.ItemIds, []string{"9"}) {
+		t.Errorf("got error %v, want item 9 not found", err)
+	}
+	if len(found) != 2 || found["2"].ItemID != "2" || found["4"].ItemID != "4" {
+		t.Errorf("found %v, want items 2 and 4", found)
+	}
+	if p.retrieves[0]["state"] != "all" {
+		t.Errorf("retrieved with %v, want every state", p.retrieves[0])
+	}
+}
+
+func TestLibrarySize(t *testing.T) {
+	for _, total := range []bool{true, false} {
+		t.Run(fmt.Sprintf("total=%v", total), func(t *testing.T) {
+			p := newFakePocket(t, numberedItems(42)...)
+			p.total = total
+			size, err := p.client().LibrarySize(context.Background(), StateArchive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != 42 {
+				t.Errorf("got %d, want 42", size)
+			}
+			if params := p.retrieves[0]; params["state"] != "archive" || params["total"] != "1" ||
+				params["count"] != "1" {
+				t.Errorf("retrieved with %v", params)
+			}
+			if total && len(p.retrieves) != 1 {
+				t.Errorf("made %d calls, want 1 when pocket sends the total", len(p.retrieves))
+			}
+		})
+	}
+}
+
+func TestRetrieveRequestFromValues(t *testing.T) {
+	v, _ := url.ParseQuery("state=archive&tag=go&sort=oldest&count=10&offset=20&detailType=complete&favorite=1")
+	req, err := RetrieveRequestFromValues(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"state": "archive", "tag": "go", "sort": "oldest", "count": "10",
+		"offset": "20", "detailType": "complete", "favorite": "1"}
+	if !reflect.DeepEqual(req.params, want) {
+		t.Errorf("got params %v, want %v", req.params, want)
+	}
+
+	for _, query := range []string{"limit=5", "state=deleted", "count=-1", "count=ten", "tag=a&tag=b"} {
+		v, _ := url.ParseQuery(query)
+		if _, err := RetrieveRequestFromValues(v); err == nil {
+			t.Errorf("%s: got no error", query)
+		}
+	}
+}
+
+func TestSortedItemsFallback(t *testing.T) {
+	var resp RetrieveResponse
+	err := json.Unmarshal([]byte(`{"status":1,"list":{`+
+		`"1":{"item_id":"1","time_added":"10"},`+
+		`"2":{"item_id":"2","time_added":"30"},`+
+		`"3":{"item_id":"3","time_added":"20","sort_id":0}}}`), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := resp.SortedItems()
+	if err != ErrNoSortId {
+		t.Errorf("got %v, want ErrNoSortId", err)
+	}
+	if got, want := itemIdsOf(items), []string{"2", "3", "1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want newest first %v", got, want)
+	}
+
+	err = json.Unmarshal([]byte(`{"status":1,"list":{`+
+		`"1":{"item_id":"1","sort_id":1},"2":{"item_id":"2","sort_id":0}}}`), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items, err := resp.SortedItems(); err != nil || !reflect.DeepEqual(itemIdsOf(items), []string{"2", "1"}) {
+		t.Errorf("got %v, %v, want [2 1] by sort_id", itemIdsOf(items), err)
+	}
+}
+
+func TestRetrieveTypedOrdered(t *testing.T) {
+	p := newFakePocket(t,
+		`{"item_id":"10","sort_id":2}`, `{"item_id":"20","sort_id":0}`, `{"item_id":"30","sort_id":1}`)
+	resp, err := p.client().RetrieveTyped(NewRetrieveRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := itemIdsOf(resp.Ordered), []string{"20", "30", "10"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if len(resp.List) != 3 {
+		t.Errorf("list has %d items, want 3", len(resp.List))
+	}
+}
+
+func TestDeletedItemsInSync(t *testing.T) {
+	p := newFakePocket(t, `{"item_id":"1","status":"0"}`, `{"item_id":"2","status":"2"}`)
+	resp, err := p.client().RetrieveTyped(NewRetrieveRequest().OnlyState(StateAll).Since("100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params := p.retrieves[0]; params["state"] != "all" || params["since"] != "100" {
+		t.Errorf("retrieved with %v", params)
+	}
+	if item, ok := resp.List["2"]; !ok || item.Status != StatusDeleted {
+		t.Errorf("got %+v, want item 2 deleted", resp.List)
+	}
+}
+
+func TestModifiedWithin(t *testing.T) {
+	client := NewClient("consumer-key", WithClock(newFakeClock()))
+	req := client.ModifiedWithin(48 * time.Hour)
+	want := map[string]string{"since": "1577664000", "sort": "newest"}
+	if !reflect.DeepEqual(req.params, want) {
+		t.Errorf("got params %v, want %v", req.params, want)
+	}
+}
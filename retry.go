@@ -0,0 +1,179 @@
+package pocket
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryOptions configures how failed requests are retried. Network errors,
+// 5xx responses and rate limiting are retried; other errors are returned
+// right away. WithRetryPolicy changes which ones are retried.
+type RetryOptions struct {
+	// MaxRetries is how many times a request is retried after the first
+	// attempt. 0 disables retries.
+	MaxRetries int
+	// BaseDelay is the wait before the first retry. It doubles with every
+	// further retry, up to MaxDelay if that is set.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Jitter enables "full jitter": each wait is a random duration between 0
+	// and the computed delay, so that many clients hitting a rate limit at
+	// the same time don't all retry in lockstep.
+	Jitter bool
+	// Rand is the randomness used for jitter. It defaults to a time seeded
+	// source; set it for reproducible delays.
+	Rand *rand.Rand
+	// Budget caps the total time one call may spend retrying, counted from
+	// its first request. It's shared by all the requests of a call that makes
+	// several, like ModifyChunked or RetrieveAllItems. Once the next wait would
+	// go past it, the last error is returned. 0 means no cap.
+	Budget time.Duration
+}
+
+// Clock is the time source used for waiting between retries and for relative
+// times like ModifiedWithin. It can be replaced with WithClock, e.g. by a fake
+// clock in tests.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// private methods
+
+type retryBudgetKey struct{}
+
+type drainKey struct{}
+
+// retryContext returns the context whose cancellation ends retrying: the
+// caller's one for requests sent with withDrain, ctx itself otherwise.
+func retryContext(ctx context.Context) context.Context {
+	if caller, ok := ctx.Value(drainKey{}).(context.Context); ok {
+		return caller
+	}
+	return ctx
+}
+
+// withRetryBudget returns ctx carrying a retry deadline for a call, unless it
+// already carries one because the call is part of a larger one.
+func (client *Client) withRetryBudget(ctx context.Context) context.Context {
+	if client.retry.Budget <= 0 || ctx.Value(retryBudgetKey{}) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, retryBudgetKey{}, client.clock.Now().Add(client.retry.Budget))
+}
+
+// withinBudget reports whether waiting d still ends before the retry deadline
+// carried by ctx.
+func (client *Client) withinBudget(ctx context.Context, d time.Duration) bool {
+	deadline, ok := ctx.Value(retryBudgetKey{}).(time.Time)
+	return !ok || !client.clock.Now().Add(d).After(deadline)
+}
+
+func (client *Client) shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if retryContext(ctx).Err() != nil {
+		return false
+	}
+	if client.retryPolicy != nil {
+		return client.retryPolicy(resp, err)
+	}
+	var pErr *Error
+	if errors.As(err, &pErr) {
+		return pErr.StatusCode >= 500 || pErr.kind == ErrRateLimited
+	}
+	// anything else failed before pocket answered, e.g. a network error
+	return true
+}
+
+// retryDelay returns how long to wait before retrying after the given
+// (zero based) attempt failed with err.
+func (client *Client) retryDelay(attempt int, header http.Header, err error) time.Duration {
+	delay := client.retry.BaseDelay << uint(attempt)
+	if delay < 0 || (client.retry.MaxDelay > 0 && delay > client.retry.MaxDelay) {
+		delay = client.retry.MaxDelay
+	}
+	if client.retry.Jitter && delay > 0 {
+		delay = client.jitter(delay)
+	}
+
+	// when rate limited, there's no point retrying before the limit resets;
+	// intermediaries say when to come back with a standard Retry-After
+	var reset time.Duration
+	if errors.Is(err, ErrRateLimited) {
+		reset = rateLimitReset(header)
+	}
+	if after := retryAfter(header, client.clock.Now()); after > reset {
+		reset = after
+	}
+	if reset > delay {
+		delay = reset
+		if client.retry.MaxDelay > 0 && delay > client.retry.MaxDelay {
+			delay = client.retry.MaxDelay
+		}
+	}
+	return delay
+}
+
+// jitter returns a random duration in [0, d). rand.Rand isn't safe for
+// concurrent use, so it's guarded by randMu.
+func (client *Client) jitter(d time.Duration) time.Duration {
+	client.randMu.Lock()
+	defer client.randMu.Unlock()
+	if client.retry.Rand == nil {
+		client.retry.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return time.Duration(client.retry.Rand.Int63n(int64(d)))
+}
+
+func (client *Client) wait(ctx context.Context, d time.Duration) error {
+	ctx = retryContext(ctx)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-client.clock.After(d):
+		return nil
+	}
+}
+
+// retryAfter returns the wait asked for by a Retry-After header, given either
+// in seconds or as an http date, or 0 if there's none.
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if len(value) == 0 {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// rateLimitReset returns the time until the exhausted rate limit (user or
+// consumer key) resets, according to pocket's X-Limit-*-Reset headers.
+func rateLimitReset(header http.Header) time.Duration {
+	var reset time.Duration
+	for _, kind := range []string{"User", "Key"} {
+		if header.Get("X-Limit-"+kind+"-Remaining") != "0" {
+			continue
+		}
+		if d := time.Duration(atoi(header.Get("X-Limit-"+kind+"-Reset"))) * time.Second; d > reset {
+			reset = d
+		}
+	}
+	return reset
+}
@@ -0,0 +1,164 @@
+package pocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetryJitter(t *testing.T) {
+	newClient := func() *Client {
+		return NewClient("consumer-key", WithRetry(RetryOptions{
+			MaxRetries: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second,
+			Jitter: true, Rand: rand.New(rand.NewSource(1)),
+		}))
+	}
+	client, same := newClient(), newClient()
+	err := errors.New("connection reset")
+
+	for attempt := 0; attempt < 6; attempt++ {
+		max := 100 * time.Millisecond << uint(attempt)
+		if max > time.Second {
+			max = time.Second
+		}
+		distinct := make(map[time.Duration]bool)
+		for i := 0; i < 100; i++ {
+			d := client.retryDelay(attempt, nil, err)
+			if d < 0 || d >= max {
+				t.Fatalf("attempt %d: delay %s outside [0, %s)", attempt, d, max)
+			}
+			if d2 := same.retryDelay(attempt, nil, err); d2 != d {
+				t.Fatalf("attempt %d: got %s and %s from the same seed", attempt, d, d2)
+			}
+			distinct[d] = true
+		}
+		if len(distinct) < 50 {
+			t.Errorf("attempt %d: only %d distinct delays in 100", attempt, len(distinct))
+		}
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	clock := newFakeClock()
+	calls := 0
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, WithClock(clock), WithRetry(RetryOptions{MaxRetries: 10, BaseDelay: time.Second, Budget: 5 * time.Second}))
+
+	_, err := client.Retrieve(NewRetrieveRequest())
+	var pErr *Error
+	if !errors.As(err, &pErr) || pErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got %v, want the last 503", err)
+	}
+	// the next wait of 4s would end 7s in
+	if want := []time.Duration{time.Second, 2 * time.Second}; !reflect.DeepEqual(clock.waits, want) {
+		t.Errorf("waited %v, want %v", clock.waits, want)
+	}
+	if calls != 3 {
+		t.Errorf("made %d attempts, want 3", calls)
+	}
+}
+
+func TestRetryBudgetAcrossChunks(t *testing.T) {
+	clock := newFakeClock()
+	failures := 0
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		// every batch fails twice before it goes through
+		if failures++; failures <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		failures = 0
+		fmt.Fprint(w, `{"status":1,"action_results":[true]}`)
+	}, WithClock(clock), WithMaxActions(1),
+		WithRetry(RetryOptions{MaxRetries: 10, BaseDelay: time.Second, Budget: 5 * time.Second}))
+
+	resp, err := client.ArchiveMany(context.Background(), []string{"1", "2"})
+	if err == nil {
+		t.Fatal("got no error, want the budget used up on the second batch")
+	}
+	if len(resp.ActionResults) != 1 {
+		t.Errorf("got %d results, want those of the first batch", len(resp.ActionResults))
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, time.Second}
+	if !reflect.DeepEqual(clock.waits, want) {
+		t.Errorf("waited %v, want %v", clock.waits, want)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	statuses := []int{http.StatusBadRequest, http.StatusOK}
+	calls := 0
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statuses[calls])
+		calls++
+		fmt.Fprint(w, `{"status":1,"list":[]}`)
+	}, WithClock(newFakeClock()), WithRetry(RetryOptions{MaxRetries: 3, BaseDelay: time.Second}),
+		WithRetryPolicy(func(resp *http.Response, err error) bool {
+			return resp != nil && resp.StatusCode == http.StatusBadRequest
+		}))
+
+	if _, err := client.Retrieve(NewRetrieveRequest()); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("made %d calls, want the 400 retried once", calls)
+	}
+
+	// and the default retry of a 5xx no longer applies
+	statuses, calls = []int{http.StatusServiceUnavailable, http.StatusOK}, 0
+	if _, err := client.Retrieve(NewRetrieveRequest()); err == nil {
+		t.Error("got no error, want the 503")
+	}
+	if calls != 1 {
+		t.Errorf("made %d calls, want 1", calls)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"120", 2 * time.Minute},
+		{" 5 ", 5 * time.Second},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{"", 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		header := http.Header{"Retry-After": {tt.value}}
+		if got := retryAfter(header, now); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	for _, value := range []string{"30", "Wed, 01 Jan 2020 00:00:30 GMT"} {
+		clock := newFakeClock()
+		calls := 0
+		client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			if calls++; calls == 1 {
+				w.Header().Set("Retry-After", value)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, `{"status":1,"list":[]}`)
+		}, WithClock(clock), WithRetry(RetryOptions{MaxRetries: 1, BaseDelay: time.Second, MaxDelay: time.Minute}))
+
+		if _, err := client.Retrieve(NewRetrieveRequest()); err != nil {
+			t.Fatal(err)
+		}
+		if want := []time.Duration{30 * time.Second}; !reflect.DeepEqual(clock.waits, want) {
+			t.Errorf("%q: waited %v, want %v", value, clock.waits, want)
+		}
+	}
+}
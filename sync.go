@@ -0,0 +1,210 @@
+package pocket
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// DiffItems compares two snapshots of a list by item id. Items only in new
+// are added, items in both whose time_updated changed are updated, and items
+// only in old are deleted. An item that pocket reports with the deleted
+// status (as a since-based retrieve does) also counts as deleted.
+func DiffItems(old, new []Item) (added, updated, deleted []Item) {
+	oldById := make(map[string]Item, len(old))
+	for _, item := range old {
+		oldById[item.ItemID] = item
+	}
+
+	seen := make(map[string]bool, len(new))
+	for _, item := range new {
+		seen[item.ItemID] = true
+		prev, existed := oldById[item.ItemID]
+		switch {
+		case item.Status == StatusDeleted:
+			if existed {
+				deleted = append(deleted, item)
+			}
+		case !existed:
+			added = append(added, item)
+		case item.TimeUpdated != prev.TimeUpdated:
+			updated = append(updated, item)
+		}
+	}
+
+	for _, item := range old {
+		if !seen[item.ItemID] {
+			deleted = append(deleted, item)
+		}
+	}
+	return added, updated, deleted
+}
+
+// Watch polls for changes every interval and calls fn with the items that
+// changed since the previous poll (pocket's since param). Only polls that
+// returned items are passed to fn, except for the last call described below.
+// Use OnlyState(StateAll) on req to also see archived and deleted items.
+//
+// Watch runs until ctx is cancelled or a poll fails. Cancelling ctx doesn't
+// abort a request in flight (unless it takes longer than 10 seconds more),
+// but stops WithRetry from retrying it: Watch waits for the response, decodes
+// it and then calls fn one last time with its items (nil when cancelled
+// between polls) before returning ctx.Err(), or with nil before returning the
+// error if that poll failed. fn is therefore always called once after
+// cancellation, which makes it a good place to flush state.
+//
+// req itself isn't modified; Watch advances the since param of a copy.
+func (client *Client) Watch(ctx context.Context,
+	req *RetrieveRequest, interval time.Duration, fn func([]Item)) error {
+	if req == nil {
+		return ErrNilRequest
+	}
+	req = req.Clone()
+	// in-flight polls run to completion even once ctx is cancelled
+	pollCtx, stop := client.withDrain(ctx)
+	defer stop()
+	for {
+		resp, err := client.retrieveTyped(pollCtx, req)
+		if err != nil {
+			if ctx.Err() != nil {
+				fn(nil)
+			}
+			return err
+		}
+		items := resp.Items()
+		if ctx.Err() != nil {
+			fn(items)
+			return ctx.Err()
+		}
+		if len(items) > 0 {
+			fn(items)
+		}
+		req.Since(strconv.FormatInt(resp.Since, 10))
+
+		select {
+		case <-ctx.Done():
+			fn(nil)
+			return ctx.Err()
+		case <-client.clock.After(interval):
+		}
+	}
+}
+
+// SyncState is the state of an incremental sync that can be persisted
+// between runs: the since timestamp of the last retrieve.
+//
+//	resp, err := client.RetrieveTyped(state.NextRequest())
+//	if err != nil {
+//		...
+//	}
+//	apply(resp.Items())
+//	state.Update(resp)
+type SyncState struct {
+	Since int64 `json:"since"`
+
+	// TrackTags makes Update keep count of the items per tag, see TagCounts.
+	// This needs complete detail, which NextRequest then asks for.
+	TrackTags bool `json:"track_tags,omitempty"`
+	// ItemTags holds the tags of every item seen, by item id, when tracking
+	// tags. It's exported so that it's persisted along with Since.
+	ItemTags map[string][]string `json:"item_tags,omitempty"`
+
+	tagCounts map[string]int
+}
+
+// NextRequest returns a request for everything that changed since the last
+// sync (everything, the first time), including archived and deleted items.
+func (s *SyncState) NextRequest() *RetrieveRequest {
+	req := NewRetrieveRequest().OnlyState(StateAll)
+	if s.Since > 0 {
+		req.Since(strconv.FormatInt(s.Since, 10))
+	}
+	if s.TrackTags {
+		req.CompleteItemInfo()
+	}
+	return req
+}
+
+// Update records the since timestamp of resp once its items were applied,
+// and their tags when tracking tags.
+func (s *SyncState) Update(resp *RetrieveResponse) {
+	if s.TrackTags {
+		s.countTags()
+		for id, item := range resp.List {
+			s.setTags(id, item)
+		}
+	}
+	if resp.Since > s.Since {
+		s.Since = resp.Since
+	}
+}
+
+// TagCounts returns how many items (deleted ones excluded) have each tag, as
+// of the last Update. It's only kept with TrackTags.
+func (s *SyncState) TagCounts() map[string]int {
+	s.countTags()
+	counts := make(map[string]int, len(s.tagCounts))
+	for tag, n := range s.tagCounts {
+		counts[tag] = n
+	}
+	return counts
+}
+
+// private methods
+
+// drainTimeout bounds how long a request sent with withDrain may still run
+// after the caller's context is done.
+const drainTimeout = 10 * time.Second
+
+// withDrain returns a context that outlives ctx by up to drainTimeout, so
+// that a request already sent can complete and its response be used. Retries
+// and backoff still stop as soon as ctx is done. stop releases the context.
+func (client *Client) withDrain(ctx context.Context) (context.Context, func()) {
+	drainCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stopAfter := context.AfterFunc(ctx, func() {
+		select {
+		case <-drainCtx.Done():
+		case <-client.clock.After(drainTimeout):
+			cancel()
+		}
+	})
+	return context.WithValue(drainCtx, drainKey{}, ctx), func() {
+		stopAfter()
+		cancel()
+	}
+}
+
+// countTags builds the counts from ItemTags the first time they're needed,
+// e.g. after the state was loaded from disk; Update keeps them current after
+// that.
+func (s *SyncState) countTags() {
+	if s.tagCounts != nil {
+		return
+	}
+	s.tagCounts = make(map[string]int)
+	for _, tags := range s.ItemTags {
+		for _, tag := range tags {
+			s.tagCounts[tag]++
+		}
+	}
+}
+
+func (s *SyncState) setTags(id string, item Item) {
+	for _, tag := range s.ItemTags[id] {
+		if s.tagCounts[tag]--; s.tagCounts[tag] == 0 {
+			delete(s.tagCounts, tag)
+		}
+	}
+	if item.Status == StatusDeleted {
+		delete(s.ItemTags, id)
+		return
+	}
+
+	if s.ItemTags == nil {
+		s.ItemTags = make(map[string][]string)
+	}
+	s.ItemTags[id] = item.Tags
+	for _, tag := range item.Tags {
+		s.tagCounts[tag]++
+	}
+}
@@ -0,0 +1,215 @@
+package pocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDiffItems(t *testing.T) {
+	old := []Item{
+		{ItemID: "1", TimeUpdated: 10},
+		{ItemID: "2", TimeUpdated: 10},
+		{ItemID: "3", TimeUpdated: 10},
+		{ItemID: "5", TimeUpdated: 10},
+	}
+	new := []Item{
+		{ItemID: "1", TimeUpdated: 10},
+		{ItemID: "2", TimeUpdated: 20},
+		{ItemID: "4", TimeUpdated: 20},
+		{ItemID: "5", TimeUpdated: 20, Status: StatusDeleted},
+	}
+
+	added, updated, deleted := DiffItems(old, new)
+	if got := itemIdsOf(added); !reflect.DeepEqual(got, []string{"4"}) {
+		t.Errorf("added %v, want [4]", got)
+	}
+	if got := itemIdsOf(updated); !reflect.DeepEqual(got, []string{"2"}) {
+		t.Errorf("updated %v, want [2]", got)
+	}
+	if got := itemIdsOf(deleted); !reflect.DeepEqual(got, []string{"5", "3"}) {
+		t.Errorf("deleted %v, want [5 3]", got)
+	}
+}
+
+func TestWatchCancelDuringPoll(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	polls := 0
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		if polls == 2 {
+			// the poll is in flight when the caller gives up
+			cancel()
+		}
+		fmt.Fprintf(w, `{"status":1,"since":%d,"list":{"%d":{"item_id":"%d","sort_id":0}}}`,
+			100+polls, polls, polls)
+	})
+
+	var calls [][]Item
+	err := client.Watch(ctx, NewRetrieveRequest(), time.Millisecond, func(items []Item) {
+		calls = append(calls, items)
+	})
+	if err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("fn called %d times, want 2", len(calls))
+	}
+	if got := itemIdsOf(calls[1]); !reflect.DeepEqual(got, []string{"2"}) {
+		t.Errorf("last call got %v, want the items of the poll in flight", got)
+	}
+}
+
+func TestWatchFailedPollAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var sinces []string
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		sinces = append(sinces, requestParams(t, r)["since"])
+		if len(sinces) == 2 {
+			cancel()
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"status":1,"since":101,"list":{"1":{"item_id":"1","sort_id":0}}}`)
+	})
+
+	req := NewRetrieveRequest()
+	var calls [][]Item
+	err := client.Watch(ctx, req, time.Millisecond, func(items []Item) {
+		calls = append(calls, items)
+	})
+	var pocketErr *Error
+	if !errors.As(err, &pocketErr) {
+		t.Errorf("got %v, want the error of the failed poll", err)
+	}
+	if len(calls) != 2 || calls[1] != nil {
+		t.Errorf("fn called with %v, want the first poll's items and then nil", calls)
+	}
+	if !reflect.DeepEqual(sinces, []string{"", "101"}) {
+		t.Errorf("polls sent since %q, want \"\" then 101", sinces)
+	}
+	if _, ok := req.params["since"]; ok {
+		t.Error("Watch modified the caller's request")
+	}
+}
+
+func TestWatchCancelStopsRetries(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls int32
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 2 {
+			cancel()
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, WithRetry(RetryOptions{MaxRetries: 3, BaseDelay: 50 * time.Millisecond}))
+
+	// the first poll fails before the cancel and is retried once
+	var fnCalls [][]Item
+	err := client.Watch(ctx, NewRetrieveRequest(), time.Millisecond, func(items []Item) {
+		fnCalls = append(fnCalls, items)
+	})
+	var pocketErr *Error
+	if !errors.As(err, &pocketErr) || pocketErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got %v, want the 503 of the poll in flight", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("made %d calls, want no retries after the cancel", n)
+	}
+	if len(fnCalls) != 1 || fnCalls[0] != nil {
+		t.Errorf("fn called with %v, want nil once", fnCalls)
+	}
+}
+
+func TestWatchUsesClock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := newFakeClock()
+	polls := 0
+	client := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if polls++; polls == 3 {
+			cancel()
+		}
+		fmt.Fprint(w, `{"status":1,"since":100,"list":[]}`)
+	}, WithClock(clock))
+
+	err := client.Watch(ctx, NewRetrieveRequest(), time.Hour, func([]Item) {})
+	if err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	if len(clock.waits) < 2 || clock.waits[0] != time.Hour || clock.waits[1] != time.Hour {
+		t.Errorf("waited %v on the clock, want the interval between polls", clock.waits)
+	}
+}
+
+func TestSyncState(t *testing.T) {
+	p := newFakePocket(t, numberedItems(2)...)
+	client := p.client()
+	var state SyncState
+
+	for cycle, since := range []int64{100, 200} {
+		p.since = since
+		resp, err := client.RetrieveTyped(state.NextRequest())
+		if err != nil {
+			t.Fatal(err)
+		}
+		state.Update(resp)
+		if state.Since != since {
+			t.Errorf("cycle %d: since is %d, want %d", cycle, state.Since, since)
+		}
+	}
+
+	if params := p.retrieves[0]; params["state"] != "all" || params["since"] != "" {
+		t.Errorf("first sync retrieved with %v, want everything", params)
+	}
+	if params := p.retrieves[1]; params["state"] != "all" || params["since"] != "100" {
+		t.Errorf("second sync retrieved with %v, want changes since 100", params)
+	}
+
+	// an older response doesn't move it back
+	state.Update(&RetrieveResponse{Since: 50})
+	if state.Since != 200 {
+		t.Errorf("since went back to %d", state.Since)
+	}
+}
+
+func TestTagCounts(t *testing.T) {
+	state := SyncState{TrackTags: true}
+	if state.NextRequest().params["detailType"] != "complete" {
+		t.Error("tracking tags without complete detail")
+	}
+	update := func(list string) {
+		var resp RetrieveResponse
+		if err := json.Unmarshal([]byte(`{"status":1,"since":1,"list":`+list+`}`), &resp); err != nil {
+			t.Fatal(err)
+		}
+		state.Update(&resp)
+	}
+
+	update(`{"1":{"item_id":"1","tags":{"go":{"tag":"go"},"web":{"tag":"web"}}},` +
+		`"2":{"item_id":"2","tags":{"go":{"tag":"go"}}}}`)
+	if got, want := state.TagCounts(), map[string]int{"go": 2, "web": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after the first sync got %v, want %v", got, want)
+	}
+
+	update(`{"1":{"item_id":"1","tags":{"web":{"tag":"web"}}},"2":{"item_id":"2","status":"2"}}`)
+	if got, want := state.TagCounts(), map[string]int{"web": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after the second sync got %v, want %v", got, want)
+	}
+
+	// counts are rebuilt from the persisted item tags
+	restored := SyncState{TrackTags: true, ItemTags: state.ItemTags}
+	if got, want := restored.TagCounts(), map[string]int{"web": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("restored state got %v, want %v", got, want)
+	}
+}